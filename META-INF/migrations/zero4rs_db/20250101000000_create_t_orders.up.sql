@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS t_orders (
+    f_id                 BIGINT        NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    f_waiter_id          BIGINT        NULL,
+    f_waiter_name        VARCHAR(64)   NULL,
+    f_waiter_name_pinyin VARCHAR(128)  NULL,
+    f_waiter_name_space  VARCHAR(128)  NULL,
+    f_item_name          VARCHAR(128)  NULL,
+    f_item_name_pinyin   VARCHAR(256)  NULL,
+    f_item_name_space    VARCHAR(256)  NULL,
+    f_vip_name           VARCHAR(64)   NULL,
+    f_vip_phone          VARCHAR(32)   NULL,
+    f_remaining_amount   DECIMAL(18,2) NULL,
+    f_pay_uuid           VARCHAR(64)   NULL,
+    f_pay_time           DATETIME      NULL,
+    f_started_at         DATETIME      NULL,
+    f_price_unit         DECIMAL(18,2) NULL,
+    f_price_real         DECIMAL(18,2) NULL,
+    f_price_deal         DECIMAL(18,2) NULL,
+    f_payment            VARCHAR(32)   NULL,
+    f_status             INT           NULL,
+    f_creator            VARCHAR(64)   NULL,
+    f_updated_at         DATETIME      NULL,
+    f_created_at         DATETIME      NULL
+);
@@ -5,7 +5,6 @@ go 1.25.5
 require (
 	github.com/99designs/gqlgen v0.17.85
 	github.com/IBM/sarama v1.46.3
-	github.com/JGLTechnologies/gin-rate-limit v1.5.6
 	github.com/alecthomas/kong v1.13.0
 	github.com/bsm/redislock v0.9.4
 	github.com/bytedance/gopkg v0.1.3
@@ -20,6 +19,7 @@ require (
 	github.com/go-sql-driver/mysql v1.9.3
 	github.com/go-zookeeper/zk v1.0.4
 	github.com/google/uuid v1.6.0
+	github.com/hashicorp/golang-lru/v2 v2.0.7
 	github.com/hhsnopek/etag v0.0.0-20171206181245-aea95f647346
 	github.com/klauspost/cpuid/v2 v2.3.0
 	github.com/mitchellh/mapstructure v1.5.0
@@ -67,7 +67,6 @@ require (
 	github.com/golang/snappy v1.0.0 // indirect
 	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/hashicorp/go-uuid v1.0.3 // indirect
-	github.com/hashicorp/golang-lru/v2 v2.0.7 // indirect
 	github.com/jcmturner/aescts/v2 v2.0.0 // indirect
 	github.com/jcmturner/dnsutils/v2 v2.0.0 // indirect
 	github.com/jcmturner/gofork v1.7.6 // indirect
@@ -1,6 +1,10 @@
 package boot
 
 import (
+	"context"
+	"os"
+
+	"github.com/chunhui2001/zero4go/pkg/cli"
 	_ "github.com/chunhui2001/zero4go/pkg/config"
 	"github.com/chunhui2001/zero4go/pkg/gkafka"
 	"github.com/chunhui2001/zero4go/pkg/gredis"
@@ -9,14 +13,22 @@ import (
 	"github.com/chunhui2001/zero4go/pkg/http_client"
 	"github.com/chunhui2001/zero4go/pkg/logs"
 	"github.com/chunhui2001/zero4go/pkg/middlewares"
+	"github.com/chunhui2001/zero4go/pkg/ratelimit"
 	"github.com/chunhui2001/zero4go/pkg/search_elastic"
 	"github.com/chunhui2001/zero4go/pkg/search_openes"
+	"github.com/chunhui2001/zero4go/pkg/single"
+	"github.com/chunhui2001/zero4go/pkg/tracing"
 )
 
 func init() {
 	// 初始化日志
 	logs.InitLog()
 
+	// 最后关闭: 发送其他钩子关闭过程中的日志
+	single.AddHook("logs-kafka", single.PriorityLogger, 0, logs.CloseKafkaWriter)
+
+	tracing.Init()
+
 	http_client.Init()
 	gkafka.Init()
 	gredis.Init()
@@ -27,4 +39,16 @@ func init() {
 	gzook.Init()
 
 	middlewares.Init()
+	ratelimit.Init()
+
+	// zero4go migrate up|down|status: 执行完迁移后退出, 不启动服务
+	if action, args, ok := cli.Cli.Migrating(); ok {
+		if err := gsql.RunMigrations(context.Background(), action, args.DataSource, args.Steps); err != nil {
+			logs.Log.Errorf("Migrate-Failed: Action=%s, Error=%s", action, err.Error())
+
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
 }
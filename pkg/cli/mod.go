@@ -18,17 +18,53 @@ type CLI struct {
 	ApolloName      string `help:"apollo application name" short:"n"`
 	ApolloProfile   string `help:"apollo profile name" short:"p"`
 	ApolloNamespace string `help:"apollo namespace, default: 'application.properties,application.yaml'" short:"s"`
+
+	Serve   ServeCmd   `cmd:"" default:"1" help:"start the http and grpc server (default)"`
+	Migrate MigrateCmd `cmd:"" help:"run schema migrations for the mysql datasources, e.g. 'zero4go migrate up -e dev'"`
+
+	// Command 选中的子命令, 如 "serve", "migrate up"
+	Command string `kong:"-"`
 }
 
-func (c *CLI) Run() error {
+type ServeCmd struct{}
+
+func (c *ServeCmd) Run() error {
 	x.Info()
 
-	// 设置命令行参数
-	cliResolver()
+	return nil
+}
 
+// MigrateCmd 迁移在配置加载和数据源初始化之后才能执行, 这里只记录参数, 由 boot 执行
+type MigrateCmd struct {
+	Up     MigrateArgs `cmd:"" help:"apply pending migrations"`
+	Down   MigrateArgs `cmd:"" help:"roll back applied migrations, one by default"`
+	Status MigrateArgs `cmd:"" help:"list migrations and when they were applied"`
+}
+
+type MigrateArgs struct {
+	DataSource string `help:"datasource name (MYSQL_NAME), default: every datasource with MYSQL_MIGRATION_LOCATION" short:"d"`
+	Steps      int    `help:"number of migrations to apply or roll back, default: all for up, 1 for down"`
+}
+
+// Run 只满足 kong 对子命令的要求, 迁移由 boot 在数据源初始化之后执行 (见 Migrating)
+func (a *MigrateArgs) Run() error {
 	return nil
 }
 
+// Migrating 是否为 migrate 子命令, 返回动作 (up / down / status) 和参数
+func (c *CLI) Migrating() (string, *MigrateArgs, bool) {
+	switch c.Command {
+	case "migrate up":
+		return "up", &c.Migrate.Up, true
+	case "migrate down":
+		return "down", &c.Migrate.Down, true
+	case "migrate status":
+		return "status", &c.Migrate.Status, true
+	}
+
+	return "", nil, false
+}
+
 func init() {
 	// 设置控制台日志输出
 	stdout.SetOutputWriter()
@@ -38,6 +74,11 @@ func init() {
 		kong.Description("Rust clap style CLI in Go using kong."),
 	)
 
+	Cli.Command = ctx.Command()
+
+	// 设置命令行参数
+	cliResolver()
+
 	// Run subcommand
 	if err := ctx.Run(&Cli); err != nil {
 		log.Printf("error=%v", err)
@@ -61,5 +102,5 @@ func cliResolver() {
 		Cli.ApolloNamespace = "application.properties,application.yaml"
 	}
 
-	log.Printf("root command running: env=%s", Cli.Env)
+	log.Printf("root command running: env=%s, command=%s", Cli.Env, Cli.Command)
 }
@@ -1,19 +1,26 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/chunhui2001/zero4go/pkg/gkafka"
 	"github.com/chunhui2001/zero4go/pkg/gredis"
 	"github.com/chunhui2001/zero4go/pkg/gsql"
 	"github.com/chunhui2001/zero4go/pkg/gzook"
+	"github.com/chunhui2001/zero4go/pkg/health"
 	"github.com/chunhui2001/zero4go/pkg/http_client"
 	"github.com/chunhui2001/zero4go/pkg/logs"
+	"github.com/chunhui2001/zero4go/pkg/metrics"
+	"github.com/chunhui2001/zero4go/pkg/ratelimit"
 	"github.com/chunhui2001/zero4go/pkg/search_elastic"
 	"github.com/chunhui2001/zero4go/pkg/search_openes"
+	"github.com/chunhui2001/zero4go/pkg/single"
+	"github.com/chunhui2001/zero4go/pkg/tracing"
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
@@ -39,6 +46,73 @@ var AppSetting = &AppConf{
 	RpcPort:  "0.0.0.0:50051",
 }
 
+// ServerConf server.Setup 安装的内置中间件和默认路由, 可被 server.Option 覆盖
+type ServerConf struct {
+	// Middlewares 按顺序安装的内置中间件, 逗号分隔: recovery, request_id, tracing, metrics, ratelimit, gzip, static, favicon, access_log
+	Middlewares string `mapstructure:"SERVER_MIDDLEWARES"`
+	// Routes 注册的默认路由, 逗号分隔: info (/info), gsql_stats (/metrics/gsql), health (/health/live, /health/ready), metrics (/metrics)
+	Routes string `mapstructure:"SERVER_ROUTES"`
+
+	GzipLevel              int    `mapstructure:"SERVER_GZIP_LEVEL"`
+	GzipExcludedExtensions string `mapstructure:"SERVER_GZIP_EXCLUDED_EXTENSIONS"`
+
+	StaticPrefix string `mapstructure:"SERVER_STATIC_PREFIX"`
+	StaticRoot   string `mapstructure:"SERVER_STATIC_ROOT"` // 相对路径基于 utils.RootDir()
+
+	// TrustedProxies 信任其 X-Forwarded-For / X-Real-IP 的代理, 逗号分隔的 IP 或 CIDR; 客户端 IP 取自第一个不受信任的地址
+	TrustedProxies string `mapstructure:"SERVER_TRUSTED_PROXIES"`
+
+	AccessLog AccessLogConf `mapstructure:",squash"`
+
+	// Upstreams 反向代理路由, 由 application.yml 的 Upstreams 加载
+	Upstreams []UpstreamConf `mapstructure:"-"`
+}
+
+// AccessLogConf access_log 中间件
+type AccessLogConf struct {
+	SkipPaths string `mapstructure:"SERVER_ACCESS_LOG_SKIP_PATHS"`
+	Format    string `mapstructure:"SERVER_ACCESS_LOG_FORMAT"` // json: 每个字段单独输出; text: 一行 Access ip "METHOD path proto status latency"
+	// Fields json 格式输出的字段, 逗号分隔: client_ip, method, path, query, proto, status, latency, user_agent, referer,
+	// request_bytes, response_bytes, route, request_id, user_id, trace_id, upstream, error
+	Fields string `mapstructure:"SERVER_ACCESS_LOG_FIELDS"`
+
+	// 记录请求体和响应体 (只记录 json / form / text / xml), 超过 BodyMaxSize 字节截断; RedactKeys 中的字段值替换为 ***
+	Body        bool   `mapstructure:"SERVER_ACCESS_LOG_BODY"`
+	BodyMaxSize int    `mapstructure:"SERVER_ACCESS_LOG_BODY_MAX_SIZE"`
+	RedactKeys  string `mapstructure:"SERVER_ACCESS_LOG_REDACT_KEYS"`
+
+	// SampleRatio 记录的比例 0~1; SampleRoutes 按路由模板单独设置, 如 /api/ping=0.01; 状态码 >= 500 的请求总是记录
+	SampleRatio  float64 `mapstructure:"SERVER_ACCESS_LOG_SAMPLE_RATIO"`
+	SampleRoutes string  `mapstructure:"SERVER_ACCESS_LOG_SAMPLE_ROUTES"`
+
+	// File 不为空时写入单独的文件 (按 LOG_FILE_MAX_* 切分), 而不是应用日志
+	File string `mapstructure:"SERVER_ACCESS_LOG_FILE"`
+}
+
+type UpstreamConf struct {
+	From    string
+	To      string
+	Remotes []string
+}
+
+var ServerSetting = &ServerConf{
+	Middlewares:            "recovery,request_id,tracing,metrics,ratelimit,gzip,static,favicon,access_log",
+	Routes:                 "info,gsql_stats,health,metrics",
+	GzipLevel:              -1, // gzip.DefaultCompression
+	GzipExcludedExtensions: ".pdf,.mp4,.ico",
+	StaticPrefix:           "/RichMedias",
+	StaticRoot:             "./static",
+	TrustedProxies:         "127.0.0.1,::1,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16",
+	AccessLog: AccessLogConf{
+		SkipPaths:   "/favicon.ico,/static,/health/live,/health/ready,/metrics",
+		Format:      "json",
+		Fields:      "client_ip,method,path,status,latency,user_agent,request_bytes,response_bytes,route,request_id,user_id,trace_id,upstream,error",
+		BodyMaxSize: 4096,
+		RedactKeys:  "password,passwd,secret,token,access_token,refresh_token,authorization,SecretAccessKey",
+		SampleRatio: 1,
+	},
+}
+
 var viperConfig *viper.Viper
 
 var onChangeFuncs []func()
@@ -85,6 +159,28 @@ func loadConfig() {
 			os.Exit(3)
 		}
 
+		if err := v1.Unmarshal(ServerSetting); err != nil {
+			log.Printf("viper parse ServerConf error: configRoot=%s, errorMessage=%v", configRoot(), err)
+
+			os.Exit(3)
+		}
+
+		if _c, ok := v1.Get("Upstreams").([]interface{}); ok {
+			var _upstreams []UpstreamConf
+
+			for _, c := range _c {
+				if m, ok := c.(map[string]any); ok {
+					_upstreams = append(_upstreams, UpstreamConf{
+						From:    stringOf(m, "from", ""),
+						To:      stringOf(m, "to", ""),
+						Remotes: stringsOf(m, "remotes"),
+					})
+				}
+			}
+
+			ServerSetting.Upstreams = _upstreams
+		}
+
 		if err := v1.Unmarshal(logs.LogSetting); err != nil {
 			log.Printf("viper parse LogConf error: configRoot=%s, errorMessage=%v", configRoot(), err)
 
@@ -133,6 +229,82 @@ func loadConfig() {
 			os.Exit(3)
 		}
 
+		if err := v1.Unmarshal(single.Settings); err != nil {
+			log.Printf("viper parse ShutdownConf error: configRoot=%s, errorMessage=%v", configRoot(), err)
+
+			os.Exit(3)
+		}
+
+		if err := v1.Unmarshal(health.Settings); err != nil {
+			log.Printf("viper parse HealthConf error: configRoot=%s, errorMessage=%v", configRoot(), err)
+
+			os.Exit(3)
+		}
+
+		if err := v1.Unmarshal(metrics.Settings); err != nil {
+			log.Printf("viper parse MetricsConf error: configRoot=%s, errorMessage=%v", configRoot(), err)
+
+			os.Exit(3)
+		}
+
+		if err := v1.Unmarshal(tracing.Settings); err != nil {
+			log.Printf("viper parse TracingConf error: configRoot=%s, errorMessage=%v", configRoot(), err)
+
+			os.Exit(3)
+		}
+
+		if tracing.Settings.ServiceName == "" {
+			tracing.Settings.ServiceName = AppSetting.AppName
+		}
+
+		if err := v1.Unmarshal(ratelimit.Settings); err != nil {
+			log.Printf("viper parse RateLimitConf error: configRoot=%s, errorMessage=%v", configRoot(), err)
+
+			os.Exit(3)
+		}
+
+		// 限流按毫秒计数, 小于 1ms 的窗口无法计数
+		if ratelimit.Settings.Enable && ratelimit.Settings.Window < time.Millisecond {
+			log.Printf("viper parse RateLimitConf error: configRoot=%s, errorMessage=RATE_LIMIT_WINDOW must be at least 1ms, got %s", configRoot(), ratelimit.Settings.Window)
+
+			os.Exit(3)
+		}
+
+		if _c, ok := v1.Get("RateLimit-Rules").([]interface{}); ok {
+			var _rules []ratelimit.Rule
+
+			for _, c := range _c {
+				m, ok := c.(map[string]any)
+
+				if !ok {
+					continue
+				}
+
+				rule := ratelimit.Rule{
+					Name:      stringOf(m, "name", ""),
+					Path:      stringOf(m, "path", "/"),
+					Methods:   stringsOf(m, "methods"),
+					Key:       stringOf(m, "key", ""),
+					Limit:     intOf(m, "limit", 0),
+					Window:    durationOf(m, "window", 0),
+					Algorithm: stringOf(m, "algorithm", ""),
+					Burst:     intOf(m, "burst", 0),
+					Overrides: intMapOf(m, "overrides"),
+				}
+
+				// window 为 0 时使用 RATE_LIMIT_WINDOW
+				if rule.Window != 0 && rule.Window < time.Millisecond {
+					log.Printf("viper parse RateLimit-Rules error: configRoot=%s, rule=%s, errorMessage=window must be at least 1ms, got %s", configRoot(), rule.Path, rule.Window)
+
+					os.Exit(3)
+				}
+
+				_rules = append(_rules, rule)
+			}
+
+			ratelimit.Rules = _rules
+		}
+
 		if _c := v1.Get("MySQLDataSource"); _c != nil {
 			var raw = make([]map[string]any, 0)
 
@@ -152,6 +324,44 @@ func loadConfig() {
 					User:     m["MYSQL_USER_NAME"].(string),
 					Passwd:   m["MYSQL_PASSWD"].(string),
 					Location: m["MYSQL_MAPPER_LOCATION"].(string),
+					Timeout:  durationOf(m, "MYSQL_QUERY_TIMEOUT", gsql.Settings.Timeout),
+					Strict:   boolOf(m, "MYSQL_STRICT_MAPPING", false),
+					Watch:    boolOf(m, "MYSQL_MAPPER_WATCH", false),
+
+					MapperStrict: boolOf(m, "MYSQL_MAPPER_STRICT", gsql.Settings.MapperStrict),
+
+					Driver:     stringOf(m, "MYSQL_DRIVER", gsql.Settings.Driver),
+					DriverName: stringOf(m, "MYSQL_DRIVER_NAME", ""),
+
+					Replicas:             stringOf(m, "MYSQL_REPLICAS", ""),
+					ReplicaPolicy:        stringOf(m, "MYSQL_REPLICA_POLICY", gsql.Settings.ReplicaPolicy),
+					ReplicaCheckInterval: durationOf(m, "MYSQL_REPLICA_CHECK_INTERVAL", gsql.Settings.ReplicaCheckInterval),
+
+					MaxOpenConns:    intOf(m, "MYSQL_MAX_OPEN_CONNS", gsql.Settings.MaxOpenConns),
+					MaxIdleConns:    intOf(m, "MYSQL_MAX_IDLE_CONNS", gsql.Settings.MaxIdleConns),
+					ConnMaxLifetime: durationOf(m, "MYSQL_CONN_MAX_LIFETIME", gsql.Settings.ConnMaxLifetime),
+					ConnMaxIdleTime: durationOf(m, "MYSQL_CONN_MAX_IDLE_TIME", gsql.Settings.ConnMaxIdleTime),
+
+					SlowThreshold:   durationOf(m, "MYSQL_SLOW_THRESHOLD", gsql.Settings.SlowThreshold),
+					SensitiveParams: stringOf(m, "MYSQL_SENSITIVE_PARAMS", ""),
+
+					MigrationLocation:    stringOf(m, "MYSQL_MIGRATION_LOCATION", ""),
+					MigrateOnStartup:     boolOf(m, "MYSQL_MIGRATE_ON_STARTUP", false),
+					MigrationLockTimeout: durationOf(m, "MYSQL_MIGRATION_LOCK_TIMEOUT", gsql.Settings.MigrationLockTimeout),
+
+					VersionColumn:    stringOf(m, "MYSQL_VERSION_COLUMN", ""),
+					SoftDeleteColumn: stringOf(m, "MYSQL_SOFT_DELETE_COLUMN", ""),
+
+					CacheTemplates: stringOf(m, "MYSQL_CACHE_TEMPLATES", ""),
+					CacheTables:    stringOf(m, "MYSQL_CACHE_TABLES", ""),
+					CacheLocalSize: intOf(m, "MYSQL_CACHE_LOCAL_SIZE", gsql.Settings.CacheLocalSize),
+					CacheLocalTTL:  durationOf(m, "MYSQL_CACHE_LOCAL_TTL", gsql.Settings.CacheLocalTTL),
+
+					DeadlockRetries: intOf(m, "MYSQL_DEADLOCK_RETRIES", gsql.Settings.DeadlockRetries),
+					DeadlockBackoff: durationOf(m, "MYSQL_DEADLOCK_BACKOFF", gsql.Settings.DeadlockBackoff),
+
+					ConnOpts: stringOf(m, "MYSQL_CONN_OPTS", ""),
+					Params:   stringMapOf(m, "MYSQL_PARAMS"),
 				})
 			}
 
@@ -160,6 +370,117 @@ func loadConfig() {
 	}
 }
 
+// durationOf 读取可选的时长配置, 支持 "30s" 形式的字符串或以秒为单位的数字
+func durationOf(m map[string]any, key string, def time.Duration) time.Duration {
+	switch v := m[key].(type) {
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+
+		log.Printf("viper parse duration error: key=%s, val=%s", key, v)
+	case int:
+		return time.Duration(v) * time.Second
+	case float64:
+		return time.Duration(v * float64(time.Second))
+	}
+
+	return def
+}
+
+// stringOf 读取可选的字符串配置
+func stringOf(m map[string]any, key string, def string) string {
+	if v, ok := m[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+
+	return def
+}
+
+// intOf 读取可选的整数配置
+func intOf(m map[string]any, key string, def int) int {
+	switch v := m[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case string:
+		if i, err := strconv.Atoi(v); err == nil {
+			return i
+		}
+	}
+
+	return def
+}
+
+// stringMapOf 读取可选的键值对配置, 值统一转为字符串
+func stringMapOf(m map[string]any, key string) map[string]string {
+	raw, ok := m[key].(map[string]any)
+
+	if !ok {
+		return nil
+	}
+
+	out := make(map[string]string, len(raw))
+
+	for k, v := range raw {
+		out[k] = fmt.Sprint(v)
+	}
+
+	return out
+}
+
+// stringsOf 读取可选的字符串列表配置, 支持 yaml 列表或逗号分隔的字符串
+func stringsOf(m map[string]any, key string) []string {
+	var out []string
+
+	switch v := m[key].(type) {
+	case []any:
+		for _, item := range v {
+			out = append(out, fmt.Sprint(item))
+		}
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+
+	return out
+}
+
+// intMapOf 读取可选的键值对配置, 值为整数
+func intMapOf(m map[string]any, key string) map[string]int {
+	raw, ok := m[key].(map[string]any)
+
+	if !ok {
+		return nil
+	}
+
+	out := make(map[string]int, len(raw))
+
+	for k := range raw {
+		out[k] = intOf(raw, k, 0)
+	}
+
+	return out
+}
+
+// boolOf 读取可选的布尔配置
+func boolOf(m map[string]any, key string, def bool) bool {
+	switch v := m[key].(type) {
+	case bool:
+		return v
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+
+	return def
+}
+
 func GetConfig(key string) any {
 	var keyPath = strings.Split(strings.ToLower(key), ".")
 
@@ -2,12 +2,14 @@ package gkafka
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"time"
 
 	"github.com/IBM/sarama"
 
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/single"
 )
 
 // 🎯 Bonus：消费者读取 Key 和 Value（封装函数）
@@ -116,16 +118,38 @@ func CreateConsumerGroup(brokers string, groupId string, topic string, offsets i
 		return err
 	}
 
-	ctx := context.Background()
+	metered := meteredHandler{ConsumerGroupHandler: handler, groupId: groupId}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
 
 	go func() {
-		for {
-			if err := consumerGroup.Consume(ctx, topics, handler); err != nil {
+		defer close(stopped)
+
+		// Consume 在 rebalance 后返回, 需要循环调用; ctx 取消后退出
+		for ctx.Err() == nil {
+			if err := consumerGroup.Consume(ctx, topics, metered); err != nil {
+				if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+					return
+				}
+
 				Log.Errorf("Error from consumer: %v", err)
 			}
 		}
 	}()
 
+	// 停止消费: 等待当前的 ConsumeClaim 返回 (提交已标记的 offset) 后关闭消费者组
+	single.AddHook("kafka-consumer-group:"+topic+":"+groupId, single.PriorityConsumer, 0, func(hookCtx context.Context) error {
+		cancelFunc()
+
+		select {
+		case <-stopped:
+		case <-hookCtx.Done():
+		}
+
+		return consumerGroup.Close()
+	})
+
 	// Log.Infof("Consumer started...")
 
 	return nil
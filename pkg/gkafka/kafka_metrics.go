@@ -0,0 +1,64 @@
+package gkafka
+
+import (
+	"strconv"
+
+	"github.com/IBM/sarama"
+
+	"github.com/chunhui2001/zero4go/pkg/metrics"
+)
+
+// 与 gkafkav2 共用同名指标
+var (
+	produced    = metrics.NewCounter("kafka_produced_total", "发送的消息数", "topic", "result")
+	consumed    = metrics.NewCounter("kafka_consumed_total", "拉取到的消息数", "topic", "group")
+	consumerLag = metrics.NewGauge("kafka_consumer_lag", "分区最新 offset 与已消费 offset 的差值", "topic", "group", "partition")
+)
+
+func observeProduced(topic string, err error) {
+	if err != nil {
+		produced.Inc(topic, "error")
+
+		return
+	}
+
+	produced.Inc(topic, "success")
+}
+
+// meteredHandler 在消息交给 handler 之前记录消费数和消费延迟
+type meteredHandler struct {
+	sarama.ConsumerGroupHandler
+	groupId string
+}
+
+type meteredClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c meteredClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.messages
+}
+
+func (h meteredHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	topic, partition := claim.Topic(), strconv.Itoa(int(claim.Partition()))
+
+	messages := make(chan *sarama.ConsumerMessage)
+
+	go func() {
+		defer close(messages)
+
+		for msg := range claim.Messages() {
+			consumed.Inc(topic, h.groupId)
+			consumerLag.Set(float64(claim.HighWaterMarkOffset()-msg.Offset-1), topic, h.groupId, partition)
+
+			select {
+			case messages <- msg:
+			case <-sess.Context().Done():
+				return
+			}
+		}
+	}()
+
+	return h.ConsumerGroupHandler.ConsumeClaim(sess, meteredClaim{ConsumerGroupClaim: claim, messages: messages})
+}
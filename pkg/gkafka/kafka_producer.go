@@ -1,10 +1,13 @@
 package gkafka
 
 import (
+	"context"
+
 	"github.com/IBM/sarama"
 	"github.com/google/uuid"
 
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/tracing"
 )
 
 type KafkaClient struct {
@@ -13,6 +16,11 @@ type KafkaClient struct {
 }
 
 func (k KafkaClient) SendMessageAsync(topic string, message string) string {
+	return k.SendMessageAsyncContext(context.Background(), topic, message)
+}
+
+// SendMessageAsyncContext 消息头携带 ctx 的 traceparent
+func (k KafkaClient) SendMessageAsyncContext(ctx context.Context, topic string, message string) string {
 	key := uuid.New().String()
 
 	msg := &sarama.ProducerMessage{
@@ -21,12 +29,24 @@ func (k KafkaClient) SendMessageAsync(topic string, message string) string {
 		Value: sarama.StringEncoder(message),
 	}
 
+	ctx, span := startProducerSpan(ctx, msg)
+
+	injectHeaders(ctx, msg)
+
 	k.ProducerAsync.Input() <- msg
 
+	// 异步发送的结果在另一个 goroutine 中读取, Span 只记录放入发送队列
+	span.End()
+
 	return key
 }
 
 func (k KafkaClient) SendMessage(topic string, message string) string {
+	return k.SendMessageContext(context.Background(), topic, message)
+}
+
+// SendMessageContext 消息头携带 ctx 的 traceparent, 并创建 producer Span
+func (k KafkaClient) SendMessageContext(ctx context.Context, topic string, message string) string {
 	key := uuid.New().String()
 
 	msg := &sarama.ProducerMessage{
@@ -35,15 +55,58 @@ func (k KafkaClient) SendMessage(topic string, message string) string {
 		Value: sarama.StringEncoder(message),
 	}
 
+	ctx, span := startProducerSpan(ctx, msg)
+
+	defer span.End()
+
+	injectHeaders(ctx, msg)
+
 	partition, offset, err := k.ProducerSync.SendMessage(msg)
 
+	observeProduced(topic, err)
+
 	if err != nil {
+		span.SetError(err)
+
 		Log.Errorf("kafka SendMessage error: Error=%v", err.Error())
 
 		return ""
 	}
 
+	span.SetAttr("messaging.kafka.destination.partition", partition)
+	span.SetAttr("messaging.kafka.offset", offset)
+
 	Log.Infof("kafka SendMessage success: topic=%s, key=%s, partition=%d, offset=%d", topic, key, partition, offset)
 
 	return key
 }
+
+func startProducerSpan(ctx context.Context, msg *sarama.ProducerMessage) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, "send "+msg.Topic, tracing.KindProducer)
+
+	span.SetAttr("messaging.system", "kafka")
+	span.SetAttr("messaging.destination.name", msg.Topic)
+
+	return ctx, span
+}
+
+func injectHeaders(ctx context.Context, msg *sarama.ProducerMessage) {
+	tracing.Inject(ctx, func(key string, value string) {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	})
+}
+
+// MessageContext 以消息头中的 traceparent 为父, 消费者处理消息时使用:
+//
+//	ctx, span := tracing.Start(gkafka.MessageContext(ctx, msg), "process "+msg.Topic, tracing.KindConsumer)
+func MessageContext(ctx context.Context, msg *sarama.ConsumerMessage) context.Context {
+	return tracing.Extract(ctx, func(key string) string {
+		for _, h := range msg.Headers {
+			if h != nil && string(h.Key) == key {
+				return string(h.Value)
+			}
+		}
+
+		return ""
+	})
+}
@@ -1,11 +1,16 @@
 package gkafka
 
 import (
+	"context"
+	"errors"
+	"net"
 	"strings"
 
 	"github.com/IBM/sarama"
 
+	"github.com/chunhui2001/zero4go/pkg/health"
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/single"
 )
 
 type KafkaConf struct {
@@ -64,13 +69,33 @@ func Init() {
 		return
 	}
 
-	// 读取结果的 goroutine
+	// 读取结果的 goroutine, 生产者关闭后两个通道都关闭时退出
 	go func() {
-		for {
+		successes, errs := producerAsync.Successes(), producerAsync.Errors()
+
+		for successes != nil || errs != nil {
 			select {
-			case suc := <-producerAsync.Successes():
+			case suc, ok := <-successes:
+				if !ok {
+					successes = nil
+
+					continue
+				}
+
+				observeProduced(suc.Topic, nil)
+
 				Log.Infof("发送了一条消息[OK]: Topic=%s, Key=%s, offset=%d, partition=%d", suc.Topic, readKey(suc.Key), suc.Offset, suc.Partition)
-			case err := <-producerAsync.Errors():
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+
+					continue
+				}
+
+				if err.Msg != nil {
+					observeProduced(err.Msg.Topic, err)
+				}
+
 				Log.Errorf("发送了一条消息[ERR]: Error=%v", err)
 			}
 		}
@@ -81,5 +106,36 @@ func Init() {
 		ProducerAsync: producerAsync,
 	}
 
+	// 异步生产者 Close 时会发送完缓冲中的消息
+	single.AddHook("kafka-producer", single.PriorityProducer, 0, func(context.Context) error {
+		return errors.Join(producerAsync.Close(), producerSync.Close())
+	})
+
+	health.Register("kafka", func(ctx context.Context) error {
+		return dialAny(ctx, brokers)
+	})
+
 	Log.Infof("Kafka-Succeed: bootstrap_servers=%s", Settings.BootstrapServers)
 }
+
+// dialAny 任一 broker 可以建立 TCP 连接即视为可用
+func dialAny(ctx context.Context, brokers []string) error {
+	var dialer net.Dialer
+	var errs []error
+
+	for _, broker := range brokers {
+		conn, err := dialer.DialContext(ctx, "tcp", strings.TrimSpace(broker))
+
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		_ = conn.Close()
+
+		return nil
+	}
+
+	return errors.Join(errs...)
+}
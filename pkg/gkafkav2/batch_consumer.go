@@ -1,11 +1,23 @@
 package gkafkav2
 
 import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/metrics"
+	"github.com/chunhui2001/zero4go/pkg/single"
+)
+
+// 与 gkafka 共用同名指标
+var (
+	consumed    = metrics.NewCounter("kafka_consumed_total", "拉取到的消息数", "topic", "group")
+	consumerLag = metrics.NewGauge("kafka_consumer_lag", "分区最新 offset 与已消费 offset 的差值", "topic", "group", "partition")
 )
 
 type BatchKafkaConsumer struct {
@@ -15,6 +27,12 @@ type BatchKafkaConsumer struct {
 	BatchSize      int
 	CommitFlushDur time.Duration
 	GroupID        string
+
+	started   atomic.Bool
+	stop      chan struct{} // Close 时关闭, 停止拉取
+	polled    chan struct{} // 拉取 goroutine 已退出
+	flushed   chan struct{} // 剩余消息已处理并提交
+	closeOnce sync.Once
 }
 
 func NewConsumer(broker string, groupId string, topic string, offset string) *BatchKafkaConsumer {
@@ -52,6 +70,10 @@ func NewConsumer(broker string, groupId string, topic string, offset string) *Ba
 			case kafka.RevokedPartitions:
 				Log.Infof("Partition: Topic=%s, GroupId=%s, Revoked: %+v", topic, groupId, ev.Partitions)
 
+				for _, p := range ev.Partitions {
+					consumerLag.Delete(topic, groupId, strconv.Itoa(int(p.Partition)))
+				}
+
 				return c.Unassign()
 			}
 
@@ -64,14 +86,21 @@ func NewConsumer(broker string, groupId string, topic string, offset string) *Ba
 
 		Log.Infof("创建了一个 kafka 消费者: Topic=%s, GroupId=%s, Broker=%s", topic, groupId, broker)
 
-		return &BatchKafkaConsumer{
+		consumer := &BatchKafkaConsumer{
 			Consumer:       _c,
 			Topic:          topic,
 			GroupID:        groupId,
 			BatchSize:      500,
 			CommitFlushDur: 50 * time.Millisecond,
 			msgCh:          make(chan *Msg, 5000),
+			stop:           make(chan struct{}),
+			polled:         make(chan struct{}),
+			flushed:        make(chan struct{}),
 		}
+
+		single.AddHook("kafka-consumer:"+topic+":"+groupId, single.PriorityConsumer, 0, consumer.Close)
+
+		return consumer
 	}
 }
 
@@ -90,12 +119,26 @@ func (c *BatchKafkaConsumer) Start(cb func(topic string, groupId string, msgs []
 		return headers
 	}
 
+	c.started.Store(true)
+
 	go func() {
+		defer close(c.polled)
+
 		for {
+			select {
+			case <-c.stop:
+				return
+			default:
+			}
+
 			ev := c.Poll(100)
 
 			if ev == nil {
-				time.Sleep(1000 * time.Millisecond)
+				select {
+				case <-c.stop:
+					return
+				case <-time.After(1000 * time.Millisecond):
+				}
 
 				continue
 			}
@@ -104,12 +147,19 @@ func (c *BatchKafkaConsumer) Start(cb func(topic string, groupId string, msgs []
 			case *kafka.Message:
 				// c.CommitMessage(e) // 异步 FlushCommit
 
-				c.msgCh <- &Msg{
+				consumed.Inc(c.Topic, c.GroupID)
+
+				select {
+				case c.msgCh <- &Msg{
 					Key:       e.Key,
 					Headers:   readHeaders(e),
 					Value:     e.Value,
 					Partition: e.TopicPartition.Partition,
 					Offset:    int64(e.TopicPartition.Offset),
+				}:
+				case <-c.stop:
+					// 未处理的消息没有提交 offset, 重启后会重新消费
+					return
 				}
 			case kafka.Error:
 				Log.Errorf("BatchKafkaConsumer Error: Error%s", e.Error())
@@ -131,6 +181,17 @@ func (c *BatchKafkaConsumer) FlushCommit(cb func(topic string, groupId string, m
 
 	for {
 		select {
+		case <-c.polled:
+			// 拉取已停止: 处理队列中剩余的消息后退出
+			for len(c.msgCh) > 0 {
+				_msgs = append(_msgs, <-c.msgCh)
+			}
+
+			c.commit(_msgs, cb)
+
+			close(c.flushed)
+
+			return
 		case msg := <-c.msgCh:
 			_msgs = append(_msgs, msg)
 
@@ -190,5 +251,41 @@ func (c *BatchKafkaConsumer) commit(msgs []*Msg, cb func(topic string, groupId s
 		Log.Errorf("CommitOffsets failed: Topic=%s, Error=%v", c.Topic, err)
 	}
 
+	c.observeLag(commits)
+
 	return true
 }
+
+// observeLag 消费延迟 = 分区最新 offset (本地缓存的 high watermark) - 已提交的 offset
+func (c *BatchKafkaConsumer) observeLag(commits []kafka.TopicPartition) {
+	for _, tp := range commits {
+		_, high, err := c.GetWatermarkOffsets(*tp.Topic, tp.Partition)
+
+		if err != nil || high < 0 {
+			continue
+		}
+
+		consumerLag.Set(float64(max(high-int64(tp.Offset), 0)), c.Topic, c.GroupID, strconv.Itoa(int(tp.Partition)))
+	}
+}
+
+// Close 停止拉取, 处理并提交已拉取的消息后关闭消费者; 已注册为关闭钩子, 通常不需要手动调用
+func (c *BatchKafkaConsumer) Close(ctx context.Context) (err error) {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+
+		if c.started.Load() {
+			select {
+			case <-c.flushed:
+			case <-ctx.Done():
+				Log.Warnf("BatchKafkaConsumer Close timeout: Topic=%s, GroupID=%s", c.Topic, c.GroupID)
+			}
+		}
+
+		err = c.Consumer.Close()
+
+		Log.Infof("关闭了一个 kafka 消费者: Topic=%s, GroupId=%s", c.Topic, c.GroupID)
+	})
+
+	return err
+}
@@ -1,5 +1,11 @@
 package gkafkav2
 
+import (
+	"context"
+
+	"github.com/chunhui2001/zero4go/pkg/tracing"
+)
+
 type Msg struct {
 	Key       []byte
 	Headers   map[string][]byte
@@ -7,3 +13,10 @@ type Msg struct {
 	Partition int32
 	Offset    int64
 }
+
+// Context 以消息头中的 traceparent 为父, 处理消息时使用:
+//
+//	ctx, span := tracing.Start(msg.Context(ctx), "process "+topic, tracing.KindConsumer)
+func (m *Msg) Context(ctx context.Context) context.Context {
+	return tracing.ExtractMap(ctx, m.Headers)
+}
@@ -10,7 +10,9 @@ import (
 
 	"github.com/redis/go-redis/v9"
 
+	"github.com/chunhui2001/zero4go/pkg/health"
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/single"
 )
 
 var (
@@ -89,6 +91,7 @@ func Init() {
 		}
 
 		Ping()
+		addHooks()
 
 		return
 	}
@@ -129,6 +132,7 @@ func Init() {
 		}
 
 		Ping()
+		addHooks()
 
 		return
 	}
@@ -147,11 +151,26 @@ func Init() {
 		}
 
 		Ping()
+		addHooks()
 
 		return
 	}
 }
 
+// addHooks 注册指标、链路追踪和健康检查; 退出时关闭连接池, 排在使用 redis 的消费者、定时任务之后
+func addHooks() {
+	RedisClient.AddHook(metricsHook{})
+	RedisClient.AddHook(tracingHook{})
+
+	health.Register("redis", func(ctx context.Context) error {
+		return RedisClient.Ping(ctx).Err()
+	})
+
+	single.AddHook("redis", single.PriorityClient, 0, func(context.Context) error {
+		return RedisClient.Close()
+	})
+}
+
 func Ping() {
 
 	var serverInfo = "N/a"
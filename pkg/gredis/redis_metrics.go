@@ -0,0 +1,77 @@
+package gredis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/chunhui2001/zero4go/pkg/metrics"
+)
+
+var (
+	commandDuration = metrics.NewHistogram("redis_command_duration_seconds", "Redis 命令耗时, pipeline 按一次记录", nil, "command")
+	commandErrors   = metrics.NewCounter("redis_command_errors_total", "Redis 命令失败数 (不含 redis.Nil)", "command")
+)
+
+// metricsHook 按命令名记录耗时和错误数
+type metricsHook struct{}
+
+func (metricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (metricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+
+		observeCommand(strings.ToLower(cmd.Name()), start, err)
+
+		return err
+	}
+}
+
+func (metricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+
+		observeCommand("pipeline", start, err)
+
+		return err
+	}
+}
+
+func observeCommand(command string, start time.Time, err error) {
+	commandDuration.Observe(time.Since(start).Seconds(), command)
+
+	if err != nil && !errors.Is(err, redis.Nil) {
+		commandErrors.Inc(command)
+	}
+}
+
+func poolSample(value func(s *redis.PoolStats) float64) []metrics.Sample {
+	if RedisClient == nil {
+		return nil
+	}
+
+	return []metrics.Sample{{Value: value(RedisClient.PoolStats())}}
+}
+
+func init() {
+	metrics.NewGaugeFunc("redis_pool_total_connections", "连接池中的连接数", nil, func() []metrics.Sample {
+		return poolSample(func(s *redis.PoolStats) float64 { return float64(s.TotalConns) })
+	})
+	metrics.NewGaugeFunc("redis_pool_idle_connections", "连接池中空闲的连接数", nil, func() []metrics.Sample {
+		return poolSample(func(s *redis.PoolStats) float64 { return float64(s.IdleConns) })
+	})
+	metrics.NewCounterFunc("redis_pool_timeouts_total", "等待连接超时的累计次数", nil, func() []metrics.Sample {
+		return poolSample(func(s *redis.PoolStats) float64 { return float64(s.Timeouts) })
+	})
+}
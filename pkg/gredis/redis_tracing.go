@@ -0,0 +1,64 @@
+package gredis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/chunhui2001/zero4go/pkg/tracing"
+)
+
+// tracingHook 为每个命令 (pipeline 为一个) 创建 client Span, 不记录参数
+type tracingHook struct{}
+
+func (tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := startSpan(ctx, strings.ToLower(cmd.Name()))
+
+		err := next(ctx, cmd)
+
+		endSpan(span, err)
+
+		return err
+	}
+}
+
+func (tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := startSpan(ctx, "pipeline")
+
+		span.SetAttr("db.redis.pipeline_length", len(cmds))
+
+		err := next(ctx, cmds)
+
+		endSpan(span, err)
+
+		return err
+	}
+}
+
+func startSpan(ctx context.Context, operation string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, "redis "+operation, tracing.KindClient)
+
+	span.SetAttr("db.system", "redis")
+	span.SetAttr("db.operation", operation)
+
+	return ctx, span
+}
+
+func endSpan(span *tracing.Span, err error) {
+	if !errors.Is(err, redis.Nil) {
+		span.SetError(err)
+	}
+
+	span.End()
+}
@@ -0,0 +1,115 @@
+package gsqltest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var update = flag.Bool("gsqltest.update", false, "rewrite gsqltest golden files")
+
+func updating() bool {
+	return *update || os.Getenv("GSQLTEST_UPDATE") == "1"
+}
+
+// AssertGolden 与 golden 文件 <goldenDir>/<tplName>[.<name>].golden 比较, name 用于区分同一模板的多组参数;
+// 更新模式下改为写入 golden 文件, golden 文件不存在时测试失败并提示更新
+func (r *Result) AssertGolden(name string) *Result {
+	r.t.Helper()
+
+	file := filepath.Join(r.h.goldenDir, goldenName(r.TplName, name))
+	got := r.snapshot()
+
+	if updating() {
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			r.t.Fatalf("gsqltest: %v", err)
+		}
+
+		if err := os.WriteFile(file, []byte(got), 0o644); err != nil {
+			r.t.Fatalf("gsqltest: %v", err)
+		}
+
+		return r
+	}
+
+	want, err := os.ReadFile(file)
+
+	if os.IsNotExist(err) {
+		r.t.Errorf("gsqltest: golden file %s does not exist, run go test with -gsqltest.update to create it", file)
+
+		return r
+	}
+
+	if err != nil {
+		r.t.Fatalf("gsqltest: %v", err)
+	}
+
+	if string(want) != got {
+		r.t.Errorf("gsqltest: %s does not match golden file %s, run go test with -gsqltest.update to accept the change\n%s", r.TplName, file, diffLines(string(want), got))
+	}
+
+	return r
+}
+
+func goldenName(tplName string, name string) string {
+	base := strings.TrimSuffix(filepath.Base(tplName), filepath.Ext(tplName))
+
+	if name != "" {
+		base += "." + name
+	}
+
+	return base + ".golden"
+}
+
+// snapshot golden 文件内容: 合并空白后的 SQL 与逐行的绑定值, 便于在 review 中阅读 diff
+func (r *Result) snapshot() string {
+	var sb strings.Builder
+
+	sb.WriteString("-- sql\n")
+	sb.WriteString(r.Normalized())
+	sb.WriteString("\n-- binds\n")
+
+	for _, b := range r.Binds {
+		sb.WriteString(fmt.Sprintf("%T: %v\n", b, b))
+	}
+
+	return sb.String()
+}
+
+// diffLines 逐行对比, - 为 golden 文件中的内容, + 为本次渲染的内容
+func diffLines(want string, got string) string {
+	var sb strings.Builder
+
+	wl := strings.Split(want, "\n")
+	gl := strings.Split(got, "\n")
+
+	for i := 0; i < len(wl) || i < len(gl); i++ {
+		var w, g string
+
+		if i < len(wl) {
+			w = wl[i]
+		}
+
+		if i < len(gl) {
+			g = gl[i]
+		}
+
+		if w == g {
+			sb.WriteString("  " + w + "\n")
+
+			continue
+		}
+
+		if i < len(wl) {
+			sb.WriteString("- " + w + "\n")
+		}
+
+		if i < len(gl) {
+			sb.WriteString("+ " + g + "\n")
+		}
+	}
+
+	return sb.String()
+}
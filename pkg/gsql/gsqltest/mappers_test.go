@@ -0,0 +1,185 @@
+package gsqltest_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chunhui2001/zero4go/pkg/gsql"
+	"github.com/chunhui2001/zero4go/pkg/gsql/gsqltest"
+)
+
+const mappers = "../../../META-INF/mappers/*.txt"
+
+type order struct {
+	ID          int64
+	FWaiterID   int64
+	FWaiterName string
+	FPriceDeal  float64
+	FCreatedAt  string
+}
+
+// mapperCase 一组渲染参数, name 区分同一模板的多组参数 (golden 文件名的后缀)
+type mapperCase struct {
+	tplName string
+	name    string
+	action  string
+	params  map[string]any
+}
+
+var orderList = []order{
+	{ID: 1, FWaiterID: 7, FWaiterName: "alice", FPriceDeal: 12.5, FCreatedAt: "2025-01-01 12:00:00"},
+	{ID: 2, FWaiterID: 8, FWaiterName: "bob", FPriceDeal: 30, FCreatedAt: "2025-01-02 12:00:00"},
+}
+
+var mapperCases = []mapperCase{
+	{tplName: "order_delete.txt", action: "Delete", params: map[string]any{"id": 3}},
+	{tplName: "order_insert.txt", action: "Insert", params: map[string]any{
+		"FWaiterID":   7,
+		"FWaiterName": "alice",
+		"FVipPhone":   "13800000000",
+		"FPriceDeal":  12.5,
+		"FStatus":     1,
+	}},
+	{tplName: "order_insert_bulk.txt", action: "Insert", params: map[string]any{"orderList": orderList}},
+	{tplName: "order_select.txt", params: map[string]any{}},
+	{tplName: "order_select.txt", name: "by_id", params: map[string]any{"id": 3}},
+	{tplName: "order_select_ids.txt", params: map[string]any{"id": 3}},
+	{tplName: "order_select_page.txt", params: map[string]any{"id": 3, "limit": 10}},
+	{tplName: "order_update.txt", action: "Update", params: map[string]any{
+		"ID":          3,
+		"FWaiterName": "bob",
+		"FPriceDeal":  30,
+	}},
+	{tplName: "order_update_buik.txt", action: "Insert", params: map[string]any{"orderList": orderList}},
+	{tplName: "select_user_condition_mapper.txt", params: map[string]any{"id": 100, "name": "%alice%"}},
+	{tplName: "select_user_mapper.txt", params: map[string]any{}},
+}
+
+// TestBundledMappers 按方言渲染 META-INF/mappers 下的 mapper, 与 testdata/gsql/<dialect> 下的 golden 文件比较
+func TestBundledMappers(t *testing.T) {
+	for _, dialect := range []string{"mysql", "postgres", "sqlite"} {
+		t.Run(dialect, func(t *testing.T) {
+			h := gsqltest.Load(t, mappers, gsqltest.WithDialect(dialect), gsqltest.WithGoldenDir("testdata/gsql/"+dialect))
+
+			covered := map[string]bool{}
+
+			for _, c := range mapperCases {
+				covered[c.tplName] = true
+
+				action := c.action
+
+				if action == "" {
+					action = "Select"
+				}
+
+				h.RenderAs(context.Background(), action, c.tplName, c.params).AssertGolden(c.name)
+			}
+
+			// 新增的 mapper 需要补充渲染参数
+			for _, name := range h.Mappers() {
+				if !covered[name] {
+					t.Errorf("mapper %s has no test case", name)
+				}
+			}
+		})
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	params := map[string]any{"id": 3, "limit": 10}
+
+	gsqltest.Load(t, mappers).
+		Render("order_select_page.txt", params).
+		AssertSQL("select * from t_orders WHERE f_id > ? order by f_id asc limit ? ;").
+		AssertBinds(3, 10)
+
+	gsqltest.Load(t, mappers, gsqltest.WithDialect("postgres")).
+		Render("order_select_page.txt", params).
+		AssertSQL("select * from t_orders WHERE f_id > $1 order by f_id asc limit $2 ;").
+		AssertBinds(3, 10)
+}
+
+func TestEmptyWhere(t *testing.T) {
+	gsqltest.Load(t, mappers).
+		Render("order_select.txt", map[string]any{}).
+		AssertSQL("select * from t_orders order by f_id desc ;").
+		AssertBinds()
+}
+
+func TestSqlBindInRejectsEmptyList(t *testing.T) {
+	h := gsqltest.Load(t, mappers)
+
+	if err := h.RenderErr("order_insert_bulk.txt", map[string]any{"orderList": []order{}}); err == nil {
+		t.Errorf("expected an error for an empty sql_values list")
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	softDelete := gsqltest.WithConfig(func(conf *gsql.MySQLConf) {
+		conf.SoftDeleteColumn = "f_deleted_at"
+	})
+
+	dir := t.TempDir()
+
+	writeMapper(t, dir, "order_select.txt", `select * from t_orders {{ where }}{{ end_where }}{{ soft_delete "" .CTX }};`)
+	writeMapper(t, dir, "order_join.txt", `select * from t_orders o join t_users u on u.f_id = o.f_user_id {{ where }}o.f_id > {{ sql_bind .id .CTX }}{{ end_where }}{{ soft_delete "o" .CTX }}{{ soft_delete "u" .CTX }}`)
+	writeMapper(t, dir, "order_delete.txt", `delete from t_orders {{ where }}f_id > {{ sql_bind .id .CTX }}{{ end_where }}{{ soft_delete "" .CTX }}`)
+	writeMapper(t, dir, "order_delete_alias.txt", `delete from t_orders o {{ where }}o.f_id > {{ sql_bind .id .CTX }}{{ end_where }}{{ soft_delete "o" .CTX }}`)
+	writeMapper(t, dir, "order_select_nowhere.txt", `select * from t_orders {{ soft_delete "" .CTX }};`)
+	writeMapper(t, dir, "log_select.txt", "select * from t_logs;")
+	writeMapper(t, dir, "log_delete.txt", `delete from t_logs {{ where }}f_id > {{ sql_bind .id .CTX }}{{ end_where }}`)
+
+	h := gsqltest.Load(t, filepath.Join(dir, "*.txt"), softDelete)
+
+	h.Render("order_select.txt", map[string]any{}).
+		AssertSQL("select * from t_orders WHERE `f_deleted_at` IS NULL;")
+
+	h.RenderAs(gsql.WithDeleted(context.Background()), "Select", "order_select.txt", map[string]any{}).
+		AssertSQL("select * from t_orders ;")
+
+	// 多表时按别名限定软删除列
+	h.Render("order_join.txt", map[string]any{"id": 3}).
+		AssertSQL("select * from t_orders o join t_users u on u.f_id = o.f_user_id WHERE (o.f_id > ?) AND `o`.`f_deleted_at` IS NULL AND `u`.`f_deleted_at` IS NULL").
+		AssertBinds(3)
+
+	h.RenderAs(context.Background(), "Delete", "order_delete.txt", map[string]any{"id": 3}).
+		AssertSQL("UPDATE t_orders SET `f_deleted_at` = CURRENT_TIMESTAMP WHERE (f_id > ?) AND `f_deleted_at` IS NULL").
+		AssertBinds(3)
+
+	// WithDeleted 对 Delete 无效, 已软删除的行不会再次更新删除时间
+	h.RenderAs(gsql.WithDeleted(context.Background()), "Delete", "order_delete.txt", map[string]any{"id": 3}).
+		AssertSQL("UPDATE t_orders SET `f_deleted_at` = CURRENT_TIMESTAMP WHERE (f_id > ?) AND `f_deleted_at` IS NULL")
+
+	h.RenderAs(gsql.WithHardDelete(context.Background()), "Delete", "order_delete.txt", map[string]any{"id": 3}).
+		AssertSQL("delete from t_orders WHERE f_id > ?")
+
+	if err := h.RenderAsErr(context.Background(), "Delete", "order_delete_alias.txt", map[string]any{"id": 3}); err == nil {
+		t.Errorf("expected an error for a soft delete that cannot be rewritten")
+	}
+
+	if err := h.RenderErr("order_select_nowhere.txt", map[string]any{}); err == nil {
+		t.Errorf("expected an error for a soft-deleted select without a where block")
+	}
+
+	// 没有声明 soft_delete 的表不受影响
+	h.Render("log_select.txt", map[string]any{}).
+		AssertSQL("select * from t_logs;")
+
+	h.RenderAs(context.Background(), "Delete", "log_delete.txt", map[string]any{"id": 3}).
+		AssertSQL("delete from t_logs WHERE f_id > ?")
+
+	// 声明了 soft_delete 但没有配置软删除列
+	if err := gsqltest.Load(t, filepath.Join(dir, "*.txt")).RenderErr("order_select.txt", map[string]any{}); err == nil {
+		t.Errorf("expected an error for soft_delete without MYSQL_SOFT_DELETE_COLUMN")
+	}
+}
+
+func writeMapper(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
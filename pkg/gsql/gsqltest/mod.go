@@ -0,0 +1,191 @@
+// Package gsqltest 在不连接数据库的情况下渲染 gsql 的 mapper 模板, 断言生成的 SQL 和绑定值, 并支持 golden 文件快照:
+//
+//	func TestOrderSelectPage(t *testing.T) {
+//		h := gsqltest.Load(t, "../../META-INF/mappers/*.txt")
+//
+//		h.Render("order_select_page.txt", map[string]any{"id": 3, "limit": 10}).
+//			AssertSQL("select * from t_orders WHERE f_id > ? order by f_id asc limit ? ;").
+//			AssertBinds(3, 10).
+//			AssertGolden("")
+//	}
+//
+// 模板变更后执行 go test ./... -gsqltest.update (或 GSQLTEST_UPDATE=1 go test ./...) 重新生成 golden 文件
+package gsqltest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chunhui2001/zero4go/pkg/gsql"
+	"github.com/chunhui2001/zero4go/pkg/utils"
+)
+
+type Option func(*Harness)
+
+// WithDialect 按方言渲染, 如 postgres 的占位符为 $1, $2
+func WithDialect(name string) Option {
+	return func(h *Harness) {
+		h.conf.Driver = name
+	}
+}
+
+// WithConfig 修改数据源配置, 如设置 VersionColumn / SoftDeleteColumn 以验证乐观锁和软删除约定
+func WithConfig(fn func(conf *gsql.MySQLConf)) Option {
+	return func(h *Harness) {
+		fn(&h.conf)
+	}
+}
+
+// WithGoldenDir golden 文件目录, 默认为 testdata/gsql (相对于测试所在的包目录)
+func WithGoldenDir(dir string) Option {
+	return func(h *Harness) {
+		h.goldenDir = dir
+	}
+}
+
+type Harness struct {
+	t         testing.TB
+	conf      gsql.MySQLConf
+	client    *gsql.MySQLClient
+	goldenDir string
+}
+
+// Load 加载 location (同 MYSQL_MAPPER_LOCATION, 如 ./META-INF/mappers/*.txt) 匹配的 mapper 模板, 加载失败时测试终止
+func Load(t testing.TB, location string, opts ...Option) *Harness {
+	t.Helper()
+
+	h := &Harness{
+		t:         t,
+		conf:      gsql.MySQLConf{Name: "gsqltest", Location: location, MapperStrict: true},
+		goldenDir: "testdata/gsql",
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	client, err := gsql.LoadMappers(h.conf)
+
+	if err != nil {
+		t.Fatalf("gsqltest: load mappers %s: %v", location, err)
+	}
+
+	h.client = client
+
+	return h
+}
+
+// Mappers 已加载的模板名称
+func (h *Harness) Mappers() []string {
+	return h.client.Mappers()
+}
+
+// Render 渲染模板, 渲染失败时测试终止; params 不会被修改
+func (h *Harness) Render(tplName string, params map[string]any) *Result {
+	h.t.Helper()
+
+	return h.RenderAs(context.Background(), "Select", tplName, params)
+}
+
+// RenderAs 按 action (Select / Insert / Update / Delete) 渲染, 用于验证写模板上的乐观锁和软删除约定
+func (h *Harness) RenderAs(ctx context.Context, action string, tplName string, params map[string]any) *Result {
+	h.t.Helper()
+
+	sqlStr, binds, err := h.render(ctx, action, tplName, params)
+
+	if err != nil {
+		h.t.Fatalf("gsqltest: render %s: %v", tplName, err)
+	}
+
+	return &Result{t: h.t, h: h, TplName: tplName, SQL: sqlStr, Binds: binds}
+}
+
+// RenderErr 渲染模板并返回错误, 用于断言非法参数 (如 sql_ident 白名单外的列名) 被拒绝
+func (h *Harness) RenderErr(tplName string, params map[string]any) error {
+	_, _, err := h.render(context.Background(), "Select", tplName, params)
+
+	return err
+}
+
+// RenderAsErr 同 RenderErr, 按 action 渲染, 用于断言写模板上无法应用的约定被拒绝
+func (h *Harness) RenderAsErr(ctx context.Context, action string, tplName string, params map[string]any) error {
+	_, _, err := h.render(ctx, action, tplName, params)
+
+	return err
+}
+
+func (h *Harness) render(ctx context.Context, action string, tplName string, params map[string]any) (string, []any, error) {
+	var cloned = make(map[string]any, len(params)+1)
+
+	for k, v := range params {
+		cloned[k] = v
+	}
+
+	sqlStr, binds, _, err := h.client.RenderSQLContext(ctx, action, tplName, cloned)
+
+	return sqlStr, binds, err
+}
+
+// Result 一次渲染的结果, 断言方法失败时记录错误并继续, 可链式调用
+type Result struct {
+	t       testing.TB
+	h       *Harness
+	TplName string
+	SQL     string
+	Binds   []any
+}
+
+// Normalized 合并空白后的 SQL
+func (r *Result) Normalized() string {
+	return strings.TrimSpace(utils.NormalizeSpace(r.SQL))
+}
+
+// AssertSQL 忽略空白差异比较 SQL
+func (r *Result) AssertSQL(want string) *Result {
+	r.t.Helper()
+
+	if want = strings.TrimSpace(utils.NormalizeSpace(want)); want != r.Normalized() {
+		r.t.Errorf("gsqltest: %s sql mismatch\n want: %s\n  got: %s", r.TplName, want, r.Normalized())
+	}
+
+	return r
+}
+
+// AssertContains SQL (忽略空白差异) 中包含 fragment
+func (r *Result) AssertContains(fragment string) *Result {
+	r.t.Helper()
+
+	if fragment = strings.TrimSpace(utils.NormalizeSpace(fragment)); !strings.Contains(r.Normalized(), fragment) {
+		r.t.Errorf("gsqltest: %s sql does not contain %q\n  got: %s", r.TplName, fragment, r.Normalized())
+	}
+
+	return r
+}
+
+// AssertBinds 按顺序比较绑定值, 类型也需一致 (int 与 int64 不相等)
+func (r *Result) AssertBinds(want ...any) *Result {
+	r.t.Helper()
+
+	if len(want) == 0 && len(r.Binds) == 0 {
+		return r
+	}
+
+	if !reflect.DeepEqual(want, r.Binds) {
+		r.t.Errorf("gsqltest: %s binds mismatch\n want: %s\n  got: %s", r.TplName, formatBinds(want), formatBinds(r.Binds))
+	}
+
+	return r
+}
+
+func formatBinds(binds []any) string {
+	items := make([]string, len(binds))
+
+	for i, b := range binds {
+		items[i] = fmt.Sprintf("%T(%v)", b, b)
+	}
+
+	return "[" + strings.Join(items, ", ") + "]"
+}
@@ -1,17 +1,19 @@
 package gsql
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"path/filepath"
-	"text/template"
+	"net/url"
 	"time"
 
 	"database/sql"
 
-	"github.com/chunhui2001/zero4go/pkg/utils"
 	_ "github.com/go-sql-driver/mysql"
 
+	"github.com/chunhui2001/zero4go/pkg/health"
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/single"
 )
 
 var Client MySQLClient
@@ -25,10 +27,107 @@ type MySQLConf struct {
 	User     string `mapstructure:"MYSQL_USER_NAME" json:"user_name"`
 	Passwd   string `mapstructure:"MYSQL_PASSWD" json:"passwd"`
 	Location string `mapstructure:"MYSQL_MAPPER_LOCATION" json:"mapper_location"`
+	// Driver 方言: mysql (默认) / postgres / sqlite, 或通过 RegisterDialect 注册的方言
+	Driver string `mapstructure:"MYSQL_DRIVER" json:"driver"`
+	// DriverName database/sql 驱动名, 为空时使用方言的默认驱动, 如 pgx / sqlite3
+	DriverName string `mapstructure:"MYSQL_DRIVER_NAME" json:"driver_name"`
+	// Timeout 调用方 ctx 未设置 deadline 时的默认语句超时, <= 0 表示不限制
+	Timeout time.Duration `mapstructure:"MYSQL_QUERY_TIMEOUT" json:"query_timeout"`
+	// Strict 结果集中存在无法映射到结构体字段的列时报错, 而不是静默丢弃
+	Strict bool `mapstructure:"MYSQL_STRICT_MAPPING" json:"strict_mapping"`
+	// Watch 监听 mapper 文件变更并热加载, 无需重启
+	Watch bool `mapstructure:"MYSQL_MAPPER_WATCH" json:"mapper_watch"`
+	// MapperStrict 启动 (及热加载) 时以空参数试渲染每个 mapper, 失败则加载失败; 关闭时只记录告警
+	MapperStrict bool `mapstructure:"MYSQL_MAPPER_STRICT" json:"mapper_strict"`
+	// Replicas 只读从库地址, 逗号分隔, 与主库共用库名和账号; 配置后 SelectRow/SelectRows 走从库
+	Replicas string `mapstructure:"MYSQL_REPLICAS" json:"replicas"`
+	// ReplicaPolicy 从库选择策略: round_robin (默认) 或 least_latency
+	ReplicaPolicy string `mapstructure:"MYSQL_REPLICA_POLICY" json:"replica_policy"`
+	// ReplicaCheckInterval 从库健康检查间隔, ping 失败的从库被摘除, 恢复后重新加入
+	ReplicaCheckInterval time.Duration `mapstructure:"MYSQL_REPLICA_CHECK_INTERVAL" json:"replica_check_interval"`
+
+	// 连接池设置, 主库和从库共用
+	MaxOpenConns    int           `mapstructure:"MYSQL_MAX_OPEN_CONNS" json:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"MYSQL_MAX_IDLE_CONNS" json:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"MYSQL_CONN_MAX_LIFETIME" json:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"MYSQL_CONN_MAX_IDLE_TIME" json:"conn_max_idle_time"`
+
+	// SlowThreshold 执行耗时超过该值时记录慢查询日志, <= 0 表示不记录
+	SlowThreshold time.Duration `mapstructure:"MYSQL_SLOW_THRESHOLD" json:"slow_threshold"`
+	// SensitiveParams 逗号分隔的参数名, 其绑定值在日志和 QueryHook 中脱敏
+	SensitiveParams string `mapstructure:"MYSQL_SENSITIVE_PARAMS" json:"sensitive_params"`
+
+	// MigrationLocation 迁移文件目录, 如 ./META-INF/migrations/zero4rs_db
+	MigrationLocation string `mapstructure:"MYSQL_MIGRATION_LOCATION" json:"migration_location"`
+	// MigrateOnStartup 启动时自动执行未执行的迁移
+	MigrateOnStartup bool `mapstructure:"MYSQL_MIGRATE_ON_STARTUP" json:"migrate_on_startup"`
+	// MigrationLockTimeout 等待其他节点释放迁移锁的最长时间
+	MigrationLockTimeout time.Duration `mapstructure:"MYSQL_MIGRATION_LOCK_TIMEOUT" json:"migration_lock_timeout"`
+
+	// VersionColumn 乐观锁版本列, 如 f_version: Update 的参数中包含同名键时, 自动在 where 块追加版本条件, 在 set 块追加版本号加一,
+	// 没有匹配的行时返回 *Error, errors.Is(err, ErrStaleUpdate) 成立
+	VersionColumn string `mapstructure:"MYSQL_VERSION_COLUMN" json:"version_column"`
+	// SoftDeleteColumn 软删除列, 如 f_deleted_at: 只作用于模板中以 {{ soft_delete "别名" .CTX }} 声明的表,
+	// Delete 改写为更新该列, 查询和更新的 where 块自动排除已删除的行 (WithDeleted 时包含)
+	SoftDeleteColumn string `mapstructure:"MYSQL_SOFT_DELETE_COLUMN" json:"soft_delete_column"`
+
+	// 查询缓存: CacheTemplates 为需要缓存的模板及其 TTL, 如 "order_select.txt=30s,order_select_ids.txt=1m";
+	// CacheTables 为模板的表标签, 多个表以 | 分隔, 如 "order_select.txt=t_orders,order_update.txt=t_orders";
+	// 写模板执行成功后清除标记了相同表的查询缓存. 缓存存放在 gredis.RedisClient, 本地 LRU 为第一级;
+	// 结果以 JSON 缓存, 含 interface 的结果类型 (如 map[string]any) 不缓存
+	CacheTemplates string        `mapstructure:"MYSQL_CACHE_TEMPLATES" json:"cache_templates"`
+	CacheTables    string        `mapstructure:"MYSQL_CACHE_TABLES" json:"cache_tables"`
+	CacheLocalSize int           `mapstructure:"MYSQL_CACHE_LOCAL_SIZE" json:"cache_local_size"`
+	CacheLocalTTL  time.Duration `mapstructure:"MYSQL_CACHE_LOCAL_TTL" json:"cache_local_ttl"`
+
+	// DeadlockRetries 死锁或锁等待超时时的最大重试次数, 只对非事务的 Insert/Update/Delete 和整个 Tx 生效, 0 表示不重试
+	DeadlockRetries int `mapstructure:"MYSQL_DEADLOCK_RETRIES" json:"deadlock_retries"`
+	// DeadlockBackoff 第一次重试前的等待时间, 之后每次翻倍并加随机抖动
+	DeadlockBackoff time.Duration `mapstructure:"MYSQL_DEADLOCK_BACKOFF" json:"deadlock_backoff"`
+
+	// DSN 参数: ConnOpts 为 "timeout=90s&parseTime=True" 形式 (便于写在 .env 中), Params 为 yaml 中的键值对, 同名时 Params 优先
+	ConnOpts string            `mapstructure:"MYSQL_CONN_OPTS" json:"conn_opts"`
+	Params   map[string]string `mapstructure:"MYSQL_PARAMS" json:"params"`
 }
 
 func (c *MySQLConf) connString(passwd string) string {
-	return fmt.Sprintf(`%s:%s@tcp(%s)/%s`, c.User, passwd, c.Server, c.Database)
+	return c.connStringOf(c.Server, passwd)
+}
+
+func (c *MySQLConf) connStringOf(server string, passwd string) string {
+	return c.dialect().DSN(c, server, passwd)
+}
+
+// dsnParams 合并 ConnOpts 与 Params, 值会被转义 (如 loc=Asia%2FShanghai), 避免 "/" 干扰驱动解析库名
+func (c *MySQLConf) dsnParams() string {
+	values, _ := url.ParseQuery(c.ConnOpts)
+
+	for k, v := range c.Params {
+		values.Set(k, v)
+	}
+
+	return values.Encode()
+}
+
+// open 打开到 server 的连接池, 主库和从库共用同一套连接设置
+func (c *MySQLConf) open(server string) (*sql.DB, error) {
+	if _, err := DialectOf(c.Driver); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(c.driverName(), c.connStringOf(server, c.Passwd))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// See "Important settings" section.
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+
+	return db, nil
 }
 
 var Settings = &MySQLConf{
@@ -38,6 +137,27 @@ var Settings = &MySQLConf{
 	Database: "mydb",
 	User:     "keesh",
 	Passwd:   "Cc",
+	Driver:   "mysql",
+	Timeout:  time.Second * 10,
+
+	MapperStrict: true,
+
+	ReplicaPolicy:        "round_robin",
+	ReplicaCheckInterval: time.Second * 10,
+
+	MaxOpenConns:    10,
+	MaxIdleConns:    10,
+	ConnMaxLifetime: time.Minute * 3,
+
+	SlowThreshold: time.Second,
+
+	MigrationLockTimeout: time.Minute,
+
+	CacheLocalSize: 1000,
+	CacheLocalTTL:  time.Second * 5,
+
+	DeadlockRetries: 3,
+	DeadlockBackoff: time.Millisecond * 50,
 }
 
 var Databases []MySQLConf
@@ -49,7 +169,7 @@ func Init() {
 		return
 	}
 
-	db, err := sql.Open("mysql", Settings.connString(Settings.Passwd))
+	db, err := Settings.open(Settings.Server)
 
 	if err != nil {
 		Log.Errorf("MySQL-failed: Error=%s, ConnectionString=%s", err.Error(), Settings.connString("****"))
@@ -57,17 +177,10 @@ func Init() {
 		return
 	}
 
-	// See "Important settings" section.
-	db.SetConnMaxLifetime(time.Minute * 3)
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(10)
-
 	if err := db.Ping(); err != nil {
 		Log.Error(fmt.Sprintf("MySQL-failed: Error=%s, ConnectionString=%s", err.Error(), Settings.connString("****")))
 	} else {
-		var location = filepath.Join(utils.RootDir(), Settings.Location)
-
-		if tpl, err := template.New("").Funcs(funcMaps()).ParseGlob(location); err != nil {
+		if tpl, err := loadMappers(Settings); err != nil {
 			panic(err)
 		} else {
 			Client = MySQLClient{
@@ -80,19 +193,87 @@ func Init() {
 		if version, err := Client.Version(); err == nil {
 			Log.Info(fmt.Sprintf("MySQL-Succeed: ServerVersion=%s, ConnString=%s", version, Settings.connString("****")))
 		}
+
+		Client.setupReplicas()
+		Client.setupCache()
+		Client.migrateOnStartup()
+		Client.registerHealth()
+
+		if Settings.Watch {
+			if err := Client.watchMappers(); err != nil {
+				Log.Errorf("MySQL-Mapper-Watch-Failed: Name=%s, Error=%s", Settings.Name, err.Error())
+			}
+		}
 	}
 
 	if len(Databases) > 0 {
 		// 构建多数据源
 		SetupDataSource()
 	}
+
+	single.AddHook("gsql", single.PriorityClient, 0, func(context.Context) error {
+		return CloseAll()
+	})
+}
+
+// registerHealth 主库为 mysql:<name>; 从库为 mysql:<name>:replica:<server>, 不可用时查询回退到主库, 因此不影响就绪状态
+func (s *MySQLClient) registerHealth() {
+	health.Register("mysql:"+s.conf.Name, s.PingContext)
+
+	for _, r := range s.replicas {
+		health.Register("mysql:"+s.conf.Name+":replica:"+r.server, r.PingContext, health.NonCritical())
+	}
+}
+
+// Close 停止 mapper 监听和从库健康检查, 关闭主库和从库的连接池
+func (s *MySQLClient) Close() (err error) {
+	s.closeOnce.Do(func() {
+		var errs []error
+
+		if s.watcher != nil {
+			errs = append(errs, s.watcher.Close())
+		}
+
+		if s.stop != nil {
+			close(s.stop)
+		}
+
+		for _, r := range s.replicas {
+			errs = append(errs, r.Close())
+		}
+
+		if s.DB != nil {
+			errs = append(errs, s.DB.Close())
+		}
+
+		err = errors.Join(errs...)
+
+		Log.Infof("MySQL-Closed: DataSource=%s", s.conf.Name)
+	})
+
+	return err
+}
+
+// CloseAll 关闭默认数据源和所有多数据源, 已注册为关闭钩子
+func CloseAll() error {
+	var errs []error
+
+	if Client.DB != nil {
+		errs = append(errs, Client.Close())
+	}
+
+	for _, c := range DataSouces {
+		errs = append(errs, c.Close())
+	}
+
+	return errors.Join(errs...)
 }
 
 func SetupDataSource() {
 	DataSouces = make(map[string]*MySQLClient, len(Databases))
 
 	for _, m := range Databases {
-		db, err := sql.Open("mysql", m.connString(m.Passwd))
+		db, err := m.open(m.Server)
 
 		if err != nil {
 			Log.Errorf("MySQL-failed: Name=%s, Error=%s, ConnectionString=%s", m.Name, err.Error(), m.connString("****"))
@@ -100,25 +281,18 @@ func SetupDataSource() {
 			continue
 		}
 
-		// See "Important settings" section.
-		db.SetConnMaxLifetime(time.Minute * 3)
-		db.SetMaxOpenConns(10)
-		db.SetMaxIdleConns(10)
-
 		if err := db.Ping(); err != nil {
 			Log.Error(fmt.Sprintf("MySQL-failed: Name=%s, Error=%s, ConnectionString=%s", m.Name, err.Error(), m.connString("****")))
 
 			continue
 		}
 
-		var location = filepath.Join(utils.RootDir(), m.Location)
-
-		if tpl, err := template.New("").Funcs(funcMaps()).ParseGlob(location); err != nil {
+		if tpl, err := loadMappers(&m); err != nil {
 			Log.Error(fmt.Sprintf("MySQL-failed: Name=%s, Error=%s, ConnectionString=%s", m.Name, err.Error(), m.connString("****")))
 
 			continue
 		} else {
-			client := MySQLClient{
+			client := &MySQLClient{
 				DB:     db,
 				render: tpl,
 				conf:   &m,
@@ -128,7 +302,18 @@ func SetupDataSource() {
 				Log.Info(fmt.Sprintf("MySQL-Succeed: Name=%s, ServerVersion=%s, ConnString=%s", m.Name, version, m.connString("****")))
 			}
 
-			DataSouces[m.Name] = &client
+			client.setupReplicas()
+			client.setupCache()
+			client.migrateOnStartup()
+			client.registerHealth()
+
+			if m.Watch {
+				if err := client.watchMappers(); err != nil {
+					Log.Errorf("MySQL-Mapper-Watch-Failed: Name=%s, Error=%s", m.Name, err.Error())
+				}
+			}
+
+			DataSouces[m.Name] = client
 		}
 	}
 }
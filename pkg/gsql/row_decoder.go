@@ -2,32 +2,59 @@ package gsql
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/chunhui2001/zero4go/pkg/utils"
 )
 
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// 每个类型的字段映射只解析一次
+var structMetaCache sync.Map // reflect.Type → *structMeta
+
 type RowDecoder[T any] struct {
 	typ       reflect.Type
 	isStruct  bool
 	isScalar  bool
 	isMap     bool
 	singleCol bool
+	fields    []*fieldMeta // 与列一一对应, nil 表示该列没有对应的字段
 }
 
 func NewRowDecoder[T any](cols []string) (*RowDecoder[T], error) {
+	return newRowDecoder[T](cols, false)
+}
+
+// NewStrictRowDecoder 同 NewRowDecoder, 但结果集中存在无法映射到字段的列时返回错误
+func NewStrictRowDecoder[T any](cols []string) (*RowDecoder[T], error) {
+	return newRowDecoder[T](cols, true)
+}
+
+func newRowDecoder[T any](cols []string, strict bool) (*RowDecoder[T], error) {
 	typ := utils.TypeOf[T]()
 
 	d := &RowDecoder[T]{
 		typ:       typ,
 		isStruct:  utils.IsStruct(typ),
-		isScalar:  utils.IsScalar(typ),
+		isScalar:  utils.IsScalar(typ) || isScanTarget(typ),
 		isMap:     utils.IsMapStringAny(typ),
 		singleCol: len(cols) == 1,
 	}
 
+	// time.Time / decimal.Decimal / sql.Null* 等按标量处理
+	if d.isScalar {
+		d.isStruct = false
+	}
+
 	// 非法组合校验
 	if d.isScalar && !d.singleCol {
 		return nil, fmt.Errorf("scalar type %s requires single column", typ)
@@ -42,6 +69,24 @@ func NewRowDecoder[T any](cols []string) (*RowDecoder[T], error) {
 		return nil, fmt.Errorf("unsupported type: %s", typ)
 	}
 
+	if d.isStruct {
+		meta := structMetaOf(typ)
+
+		d.fields = make([]*fieldMeta, len(cols))
+
+		var unmapped []string
+
+		for i, col := range cols {
+			if d.fields[i] = meta.lookup(col); d.fields[i] == nil {
+				unmapped = append(unmapped, col)
+			}
+		}
+
+		if strict && len(unmapped) > 0 {
+			return nil, fmt.Errorf("unmapped columns for type %s: %s", typ, strings.Join(unmapped, ", "))
+		}
+	}
+
 	return d, nil
 }
 
@@ -51,7 +96,8 @@ func (d *RowDecoder[T]) Decode(row *sql.Rows, cols []string) (T, error) {
 	switch {
 	case d.isScalar:
 		var v T
-		if err := row.Scan(&v); err != nil {
+
+		if err := row.Scan(scanTarget(reflect.ValueOf(&v).Elem(), false)); err != nil {
 			return zero, err
 		}
 
@@ -105,37 +151,271 @@ func (d *RowDecoder[T]) mapColumns(row *sql.Rows, cols []string) (*T, error) {
 	var result T
 
 	val := reflect.ValueOf(&result).Elem()
-	typ := val.Type()
 
-	// 字段名 → index 映射（支持 db tag）
-	fieldMap := make(map[string]int)
+	// scan 容器
+	values := make([]any, len(cols))
+
+	for i := range cols {
+		if f := d.fields[i]; f != nil {
+			values[i] = scanTarget(fieldByIndex(val, f.index), f.json)
+		} else {
+			var dummy any
+			values[i] = &dummy
+		}
+	}
+
+	if err := row.Scan(values...); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+type fieldMeta struct {
+	index []int
+	json  bool // db:"col,json" 列内容按 JSON 解码到字段
+}
+
+type structMeta struct {
+	byName map[string]*fieldMeta // db tag 或小写字段名
+	byNorm map[string]*fieldMeta // 去掉下划线后的小写名, 用于 f_waiter_name → FWaiterName
+}
+
+func (m *structMeta) lookup(col string) *fieldMeta {
+	if f, ok := m.byName[col]; ok {
+		return f
+	}
+
+	if f, ok := m.byName[strings.ToLower(col)]; ok {
+		return f
+	}
+
+	return m.byNorm[normalizeName(col)]
+}
+
+func structMetaOf(typ reflect.Type) *structMeta {
+	if m, ok := structMetaCache.Load(typ); ok {
+		return m.(*structMeta)
+	}
+
+	m := &structMeta{
+		byName: make(map[string]*fieldMeta),
+		byNorm: make(map[string]*fieldMeta),
+	}
+
+	m.collect(typ, nil, "", nil)
+
+	actual, _ := structMetaCache.LoadOrStore(typ, m)
+
+	return actual.(*structMeta)
+}
+
+// collect 收集字段映射: 先处理直接字段, 再处理匿名嵌入字段, 外层字段优先;
+// 非匿名的结构体字段 (非 Scanner / time.Time) 以 "<字段名>_" 为前缀展开;
+// path 为正在展开的类型, 自引用的字段 (如 Parent *Node) 不再展开
+func (m *structMeta) collect(typ reflect.Type, parent []int, prefix string, path []reflect.Type) {
+	var embedded []reflect.StructField
+
+	path = append(path, typ)
 
 	for i := 0; i < typ.NumField(); i++ {
 		f := typ.Field(i)
-		name := f.Tag.Get("db")
+		name, opts, _ := strings.Cut(f.Tag.Get("db"), ",")
+
+		if name == "-" {
+			continue
+		}
+
+		index := append(append([]int{}, parent...), i)
+		ft := indirectType(f.Type)
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct && !isScanTarget(ft) {
+			f.Index = index
+			embedded = append(embedded, f)
+
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
 
 		if name == "" {
 			name = strings.ToLower(f.Name)
 		}
 
-		fieldMap[name] = i
+		isJson := hasTagOpt(opts, "json")
+
+		if !isJson && ft.Kind() == reflect.Struct && !isScanTarget(ft) {
+			if !slices.Contains(path, ft) {
+				m.collect(ft, index, prefix+name+"_", path)
+			}
+
+			continue
+		}
+
+		m.add(prefix+name, &fieldMeta{index: index, json: isJson})
 	}
 
-	// scan 容器
-	values := make([]any, len(cols))
+	for _, f := range embedded {
+		if ft := indirectType(f.Type); !slices.Contains(path, ft) {
+			m.collect(ft, f.Index, prefix, path)
+		}
+	}
+}
 
-	for i, col := range cols {
-		if idx, ok := fieldMap[col]; ok {
-			values[i] = val.Field(idx).Addr().Interface()
-		} else {
-			var dummy any
-			values[i] = &dummy
+func (m *structMeta) add(name string, f *fieldMeta) {
+	if _, ok := m.byName[name]; !ok {
+		m.byName[name] = f
+	}
+
+	if _, ok := m.byNorm[normalizeName(name)]; !ok {
+		m.byNorm[normalizeName(name)] = f
+	}
+}
+
+// hasTagOpt db tag 逗号后的选项中是否包含 opt, 如 db:"f_id,auto" / db:"f_extra,json"
+func hasTagOpt(opts string, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if strings.TrimSpace(o) == opt {
+			return true
 		}
 	}
 
-	if err := row.Scan(values...); err != nil {
-		return nil, err
+	return false
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
 	}
 
-	return &result, nil
+	return t
+}
+
+// isScanTarget database/sql 能直接扫描的结构体类型: sql.Null*, decimal.Decimal, time.Time 等
+func isScanTarget(t reflect.Type) bool {
+	return t == timeType || reflect.PointerTo(t).Implements(scannerType)
+}
+
+// fieldByIndex 同 reflect.Value.FieldByIndex, 途经的 nil 指针 (嵌入的 *struct) 会被初始化
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	return v
+}
+
+func scanTarget(field reflect.Value, isJson bool) any {
+	if isJson {
+		return &jsonScanner{dest: field}
+	}
+
+	if indirectType(field.Type()) == timeType {
+		return &timeScanner{dest: field}
+	}
+
+	return field.Addr().Interface()
+}
+
+// jsonScanner 把 JSON 列解码到 struct / map / slice 字段
+type jsonScanner struct {
+	dest reflect.Value
+}
+
+func (s *jsonScanner) Scan(src any) error {
+	var data []byte
+
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("gsql: cannot decode %T into json field %s", src, s.dest.Type())
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(data, s.dest.Addr().Interface())
+}
+
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// timeScanner 兼容 DSN 未开启 parseTime 时 MySQL 返回的 []byte 时间; 字段可以是 time.Time 或 *time.Time
+type timeScanner struct {
+	dest reflect.Value
+}
+
+func (s *timeScanner) Scan(src any) error {
+	var tm time.Time
+
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		tm = v
+	case []byte:
+		t, err := parseTime(string(v))
+
+		if err != nil {
+			return err
+		}
+
+		tm = t
+	case string:
+		t, err := parseTime(v)
+
+		if err != nil {
+			return err
+		}
+
+		tm = t
+	default:
+		return fmt.Errorf("gsql: cannot scan %T into %s", src, s.dest.Type())
+	}
+
+	if s.dest.Kind() == reflect.Pointer {
+		s.dest.Set(reflect.ValueOf(&tm))
+	} else {
+		s.dest.Set(reflect.ValueOf(tm))
+	}
+
+	return nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" || strings.HasPrefix(s, "0000-00-00") {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range timeLayouts {
+		if tm, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return tm, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("gsql: cannot parse %q as time", s)
 }
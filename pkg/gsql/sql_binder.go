@@ -1,24 +1,62 @@
 package gsql
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"text/template"
 	//nolint:staticcheck
 )
 
-var ReGTrim = regexp.MustCompile(`,\s*__TRIM__\(,\)`)
+// 块标记: where / set / trim 先输出标记, 渲染完成后由 expandBlocks 统一处理块内容
+const (
+	blockBegin = "\uE000"
+	blockName  = "\uE001"
+	blockEnd   = "\uE002"
+)
+
+// 最内层的块 (块内不再包含其他块标记)
+var reBlock = regexp.MustCompile(`\x{E000}([^\x{E001}]*)\x{E001}([^\x{E000}\x{E002}]*)\x{E002}`)
+
+var reLeadingAndOr = regexp.MustCompile(`(?i)^(AND|OR)\b\s*`)
+
+// BindError 模板辅助函数的参数不合法 (如空列表, 不在白名单内的列名), 与模板语法错误区分开
+type BindError struct {
+	Func string
+	Msg  string
+}
+
+func (e *BindError) Error() string {
+	return e.Func + ": " + e.Msg
+}
 
 // SqlBindContext 模拟 Rust 的 Arc<Mutex<Vec<Value>>>
 type SqlBindContext struct {
-	binds []interface{}
-	mu    sync.Mutex
+	binds   []interface{}
+	mu      sync.Mutex
+	dialect Dialect
+
+	// secret 敏感绑定值的位置, 日志和 QueryHook 中按位置脱敏
+	secret []int
+	// sensitive MYSQL_SENSITIVE_PARAMS (normalizeName 后), sql_values 按字段名判断
+	sensitive map[string]bool
+
+	// softDeletes 模板以 soft_delete 声明的软删除表 (别名), 空串表示不限定表名
+	softDeletes []string
 }
 
 func NewSqlBindContext() *SqlBindContext {
-	return &SqlBindContext{}
+	return &SqlBindContext{dialect: mysqlDialect{}}
+}
+
+// NewDialectBindContext 按方言输出占位符, 如 PostgreSQL 的 $1, $2
+func NewDialectBindContext(d Dialect) *SqlBindContext {
+	return &SqlBindContext{dialect: d}
 }
 
 // AddBind 添加一个绑定值
@@ -28,6 +66,70 @@ func (ctx *SqlBindContext) AddBind(val interface{}) {
 	ctx.binds = append(ctx.binds, val)
 }
 
+// bind 添加一个绑定值, 返回其占位符
+func (ctx *SqlBindContext) bind(val interface{}) string {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.binds = append(ctx.binds, val)
+
+	if ctx.dialect == nil {
+		return "?"
+	}
+
+	return ctx.dialect.Placeholder(len(ctx.binds))
+}
+
+// bindSecret 同 bind, 并记录该位置为敏感值
+func (ctx *SqlBindContext) bindSecret(val interface{}) string {
+	holder := ctx.bind(val)
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.secret = append(ctx.secret, len(ctx.binds)-1)
+
+	return holder
+}
+
+// mask 返回 binds 的副本, 敏感位置的值替换为 ****
+func (ctx *SqlBindContext) mask(binds []any) []any {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if len(ctx.secret) == 0 {
+		return binds
+	}
+
+	out := slices.Clone(binds)
+
+	for _, i := range ctx.secret {
+		if i < len(out) {
+			out[i] = maskedValue
+		}
+	}
+
+	return out
+}
+
+// SoftDelete 声明表 (别名) 有软删除列 (MYSQL_SOFT_DELETE_COLUMN), 最外层 where 块追加 <别名>.<软删除列> IS NULL,
+// 别名为空时不限定表名; Delete 改写为更新该列. 输出为空:
+// select * from t_orders o join t_users u on ... {{ where }}...{{ end_where }} {{ soft_delete "o" .CTX }}{{ soft_delete "u" .CTX }}
+func SoftDelete(alias string, ctx *SqlBindContext) string {
+	if ctx == nil {
+		panic("soft_delete: ctx is nil")
+	}
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if !slices.Contains(ctx.softDeletes, alias) {
+		ctx.softDeletes = append(ctx.softDeletes, alias)
+	}
+
+	return ""
+}
+
 // TakeBinds 获取全部绑定值（取出后清空）
 func (ctx *SqlBindContext) TakeBinds() []interface{} {
 	ctx.mu.Lock()
@@ -39,49 +141,380 @@ func (ctx *SqlBindContext) TakeBinds() []interface{} {
 	return result
 }
 
-func funcMaps() template.FuncMap {
+func funcMaps(d Dialect) template.FuncMap {
 	return template.FuncMap{
-		"sql_bind": SqlBind,
-		"TrimComma": func(s string) string {
-			return "__TRIM__(" + strings.TrimSpace(s) + ")"
+		"sql_bind":    SqlBind,
+		"sql_bind_in": SqlBindIn,
+		"sql_values":  SqlValues,
+		// 绑定值在日志和 QueryHook 中脱敏; 参数名在 MYSQL_SENSITIVE_PARAMS 中时, 加载 mapper 时自动改写为这些函数
+		"sql_bind_secret":    SqlBindSecret,
+		"sql_bind_in_secret": SqlBindInSecret,
+		"sql_like_secret":    SqlLikeSecret,
+		"soft_delete":        SoftDelete,
+		"sql_ident": func(name string, whitelist ...string) (string, error) {
+			return sqlIdent(d, name, whitelist...)
+		},
+		"sql_sort":    SqlSort,
+		"sql_like":    SqlLike,
+		"like_escape": LikeEscape,
+		"where":       func() string { return blockBegin + "WHERE" + blockName },
+		"end_where":   func() string { return blockEnd },
+		"set":         func() string { return blockBegin + "SET" + blockName },
+		"end_set":     func() string { return blockEnd },
+		"trim": func(token string) string {
+			return blockBegin + "TRIM:" + strings.TrimSpace(token) + blockName
 		},
+		"end_trim": func() string { return blockEnd },
+
+		// Deprecated: 使用 {{ set }} / {{ trim "," }} 块
+		"TrimComma": TrimComma,
 	}
 }
 
+// ReGTrim 匹配 TrimComma 输出的标记及其前面的逗号
+//
+// Deprecated: 使用 {{ set }} / {{ trim "," }} 块
+var ReGTrim = regexp.MustCompile(`,\s*__TRIM__\(,\)`)
+
+// TrimComma 去掉其前面的逗号, 如 VALUES 列表末尾的 ,
+//
+// Deprecated: 使用 {{ set }} / {{ trim "," }} 块
+func TrimComma(s string) string {
+	return "__TRIM__(" + strings.TrimSpace(s) + ")"
+}
+
+func trimEndSymbol(out string) string {
+	return ReGTrim.ReplaceAllString(out, "")
+}
+
 // SqlBind
-// AND name = {{ sql_bind .Name .BindCtx }}
-// AND id   IN ({{ sql_bind_in .Ids .BindCtx }})
+// AND name = {{ sql_bind .Name .CTX }}
 func SqlBind(val any, ctx *SqlBindContext) string {
 	if ctx == nil {
 		panic("sql_bind: ctx is nil")
 	}
 
-	ctx.AddBind(val)
+	return ctx.bind(val)
+}
+
+// SqlBindSecret 同 SqlBind, 绑定值在日志和 QueryHook 中脱敏
+// AND f_vip_phone = {{ sql_bind_secret .FVipPhone .CTX }}
+func SqlBindSecret(val any, ctx *SqlBindContext) string {
+	if ctx == nil {
+		panic("sql_bind_secret: ctx is nil")
+	}
+
+	return ctx.bindSecret(val)
+}
+
+// SqlBindIn 展开 slice 为多个占位符; nil 或空 slice 返回错误: IN () 不是合法的 SQL,
+// 而输出 NULL 会让 NOT IN (NULL) 静默地不匹配任何行, 列表可能为空时在模板中用 if 判断
+// {{ if .Ids }} AND id IN ({{ sql_bind_in .Ids .CTX }}) {{ end }}
+func SqlBindIn(list any, ctx *SqlBindContext) (string, error) {
+	if ctx == nil {
+		panic("sql_bind_in: ctx is nil")
+	}
+
+	return bindIn(list, ctx, ctx.bind)
+}
+
+// SqlBindInSecret 同 SqlBindIn, 各绑定值在日志和 QueryHook 中脱敏
+func SqlBindInSecret(list any, ctx *SqlBindContext) (string, error) {
+	if ctx == nil {
+		panic("sql_bind_in_secret: ctx is nil")
+	}
+
+	return bindIn(list, ctx, ctx.bindSecret)
+}
+
+func bindIn(list any, ctx *SqlBindContext, bind func(val any) string) (string, error) {
+	v := reflect.ValueOf(list)
+
+	if !v.IsValid() || (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) {
+		return "", &BindError{Func: "sql_bind_in", Msg: fmt.Sprintf("expected slice, got %T", list)}
+	}
+
+	if v.Len() == 0 {
+		return "", &BindError{Func: "sql_bind_in", Msg: "empty list"}
+	}
+
+	holders := make([]string, v.Len())
 
-	return "?"
+	for i := 0; i < v.Len(); i++ {
+		holders[i] = bind(v.Index(i).Interface())
+	}
+
+	return strings.Join(holders, ", "), nil
 }
 
-// DebugSQLWithBinds 将 SQL 中的 '?' 占位符替换成绑定值的可读形式
+// SqlValues 由 struct / map 的 slice 生成多行 VALUES, fields 为 struct 字段名或 map 的 key
+// INSERT INTO t_orders (f_waiter_id, f_waiter_name) VALUES {{ sql_values .orderList .CTX "FWaiterID" "FWaiterName" }}
+func SqlValues(list any, ctx *SqlBindContext, fields ...string) (string, error) {
+	if ctx == nil {
+		panic("sql_values: ctx is nil")
+	}
+
+	if len(fields) == 0 {
+		return "", &BindError{Func: "sql_values", Msg: "no fields given"}
+	}
+
+	v := reflect.ValueOf(list)
+
+	if !v.IsValid() || (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) {
+		return "", &BindError{Func: "sql_values", Msg: fmt.Sprintf("expected slice, got %T", list)}
+	}
+
+	if v.Len() == 0 {
+		return "", &BindError{Func: "sql_values", Msg: "empty list"}
+	}
+
+	rows := make([]string, v.Len())
+
+	for i := 0; i < v.Len(); i++ {
+		item := reflect.Indirect(v.Index(i))
+
+		if item.Kind() == reflect.Interface {
+			item = reflect.Indirect(item.Elem())
+		}
+
+		holders := make([]string, len(fields))
+
+		for j, f := range fields {
+			val, ok := fieldOf(item, f)
+
+			if !ok {
+				return "", &BindError{Func: "sql_values", Msg: fmt.Sprintf("row %d has no field %s", i, f)}
+			}
+
+			if ctx.sensitive[normalizeName(f)] {
+				holders[j] = ctx.bindSecret(val)
+			} else {
+				holders[j] = ctx.bind(val)
+			}
+		}
+
+		rows[i] = "(" + strings.Join(holders, ", ") + ")"
+	}
+
+	return strings.Join(rows, ",\n"), nil
+}
+
+func fieldOf(item reflect.Value, name string) (any, bool) {
+	switch item.Kind() {
+	case reflect.Struct:
+		if f := item.FieldByName(name); f.IsValid() && f.CanInterface() {
+			return f.Interface(), true
+		}
+	case reflect.Map:
+		if f := item.MapIndex(reflect.ValueOf(name)); f.IsValid() {
+			return f.Interface(), true
+		}
+	}
+
+	return nil, false
+}
+
+// SqlIdent 动态列名 / 排序字段, 只允许白名单中的值, 输出反引号包裹的标识符; 模板中的 sql_ident 按数据源的方言引用
+// ORDER BY {{ sql_ident .orderBy "f_id" "f_created_at" }} {{ sql_sort .direction }}
+func SqlIdent(name string, whitelist ...string) (string, error) {
+	return sqlIdent(mysqlDialect{}, name, whitelist...)
+}
+
+// sqlIdent 模板中的 sql_ident 按数据源的方言引用标识符
+func sqlIdent(d Dialect, name string, whitelist ...string) (string, error) {
+	if !slices.Contains(whitelist, name) {
+		return "", &BindError{Func: "sql_ident", Msg: fmt.Sprintf("identifier %q is not allowed", name)}
+	}
+
+	return quoteIdentOf(d, name), nil
+}
+
+// SqlSort 排序方向, 只接受 asc / desc (不区分大小写), 为空时默认 ASC
+func SqlSort(direction string) (string, error) {
+	switch strings.ToUpper(strings.TrimSpace(direction)) {
+	case "", "ASC":
+		return "ASC", nil
+	case "DESC":
+		return "DESC", nil
+	}
+
+	return "", &BindError{Func: "sql_sort", Msg: fmt.Sprintf("invalid direction %q", direction)}
+}
+
+// SqlLike 绑定转义后的 %val%
+// AND f_waiter_name LIKE {{ sql_like .name .CTX }}
+func SqlLike(val string, ctx *SqlBindContext) string {
+	return SqlBind("%"+LikeEscape(val)+"%", ctx)
+}
+
+// SqlLikeSecret 同 SqlLike, 绑定值在日志和 QueryHook 中脱敏
+func SqlLikeSecret(val string, ctx *SqlBindContext) string {
+	return SqlBindSecret("%"+LikeEscape(val)+"%", ctx)
+}
+
+// LikeEscape 转义 LIKE 通配符, 用于自行拼接前缀 / 后缀匹配
+// AND f_waiter_name LIKE {{ sql_bind (printf "%s%%" (like_escape .name)) .CTX }}
+func LikeEscape(val string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(val)
+}
+
+// expandBlocks 处理 where / set / trim 块:
+// where 去掉开头的 AND/OR, 内容为空时整个 WHERE 省略; set 去掉首尾逗号; trim 去掉首尾的指定符号
+func expandBlocks(out string) (string, error) {
+	return expandBlocksWith(out, nil)
+}
+
+// blockExtras 由 gsql 的约定 (乐观锁, 软删除) 自动追加到最外层 where / set 块的条件和赋值
+type blockExtras struct {
+	where []string
+	set   []string
+
+	whereApplied int
+	setApplied   int
+}
+
+// expandBlocksWith 同 expandBlocks, extras 只追加到最外层的块, 子查询中的块不受影响;
+// 块未闭合 (缺少 end_where 等) 或多出结束标记时返回错误, 不把标记字符发给数据库
+func expandBlocksWith(out string, extras *blockExtras) (string, error) {
+	for strings.Contains(out, blockBegin) {
+		locs := reBlock.FindAllStringSubmatchIndex(out, -1)
+
+		if len(locs) == 0 {
+			return "", unclosedBlockError(out)
+		}
+
+		var sb strings.Builder
+		var last = 0
+
+		for _, loc := range locs {
+			sb.WriteString(out[last:loc[0]])
+
+			// 之前的块标记都已闭合, 即不在其他块内
+			top := strings.Count(out[:loc[0]], blockBegin) == strings.Count(out[:loc[0]], blockEnd)
+
+			sb.WriteString(expandBlock(out[loc[2]:loc[3]], strings.TrimSpace(out[loc[4]:loc[5]]), top, extras))
+
+			last = loc[1]
+		}
+
+		sb.WriteString(out[last:])
+
+		out = sb.String()
+	}
+
+	if strings.Contains(out, blockEnd) {
+		return "", errors.New("gsql: {{ end_where }} / {{ end_set }} / {{ end_trim }} without matching block")
+	}
+
+	return out, nil
+}
+
+// unclosedBlockError 报告第一个未闭合的块
+func unclosedBlockError(out string) error {
+	kind := "block"
+
+	if i := strings.Index(out, blockBegin); i >= 0 {
+		rest := out[i+len(blockBegin):]
+
+		if j := strings.Index(rest, blockName); j >= 0 {
+			kind, _, _ = strings.Cut(strings.ToLower(rest[:j]), ":")
+		}
+	}
+
+	return fmt.Errorf("gsql: unclosed {{ %s }} block, missing {{ end_%s }}", kind, kind)
+}
+
+func expandBlock(kind string, body string, top bool, extras *blockExtras) string {
+	switch {
+	case kind == "WHERE":
+		body = strings.TrimSpace(reLeadingAndOr.ReplaceAllString(body, ""))
+
+		if top && extras != nil && len(extras.where) > 0 {
+			extras.whereApplied++
+
+			// 原条件可能包含 OR, 追加条件前加括号
+			if body != "" {
+				body = "(" + body + ") AND " + strings.Join(extras.where, " AND ")
+			} else {
+				body = strings.Join(extras.where, " AND ")
+			}
+		}
+
+		if body == "" {
+			return ""
+		}
+
+		return "WHERE " + body
+	case kind == "SET":
+		body = trimToken(body, ",")
+
+		if top && extras != nil && len(extras.set) > 0 {
+			extras.setApplied++
+
+			if body != "" {
+				body += ", " + strings.Join(extras.set, ", ")
+			} else {
+				body = strings.Join(extras.set, ", ")
+			}
+		}
+
+		if body == "" {
+			return ""
+		}
+
+		return "SET " + body
+	case strings.HasPrefix(kind, "TRIM:"):
+		return trimToken(body, strings.TrimPrefix(kind, "TRIM:"))
+	}
+
+	return body
+}
+
+func trimToken(s string, token string) string {
+	s = strings.TrimSpace(s)
+
+	if token == "" {
+		return s
+	}
+
+	s = strings.TrimSpace(strings.TrimPrefix(s, token))
+	s = strings.TrimSpace(strings.TrimSuffix(s, token))
+
+	return s
+}
+
+// isBindError 渲染错误是否由模板辅助函数的参数引起
+func isBindError(err error) bool {
+	var bindErr *BindError
+
+	return errors.As(err, &bindErr)
+}
+
+// PostgreSQL 风格的占位符 $1, $2
+var reNumberedPlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+// DebugSQLWithBinds 将 SQL 中的 '?' (或 '$n') 占位符替换成绑定值的可读形式
 func DebugSQLWithBinds(sql string, binds []interface{}) string {
+	if reNumberedPlaceholder.MatchString(sql) {
+		return reNumberedPlaceholder.ReplaceAllStringFunc(sql, func(holder string) string {
+			n, _ := strconv.Atoi(holder[1:])
+
+			if n < 1 || n > len(binds) {
+				return holder
+			}
+
+			return debugValue(binds[n-1])
+		})
+	}
+
 	var sb strings.Builder
 	bindIdx := 0
 
 	for _, c := range sql {
 		if c == '?' {
 			if bindIdx < len(binds) {
-				val := binds[bindIdx]
+				sb.WriteString(debugValue(binds[bindIdx]))
 				bindIdx++
-
-				switch v := val.(type) {
-				case string:
-					// 字符串加单引号，并替换内部单引号为双单引号
-					escaped := strings.ReplaceAll(v, "'", "''")
-					sb.WriteString("'" + escaped + "'")
-				case fmt.Stringer:
-					sb.WriteString(v.String())
-				default:
-					sb.WriteString(fmt.Sprintf("%v", v))
-				}
 			} else {
 				sb.WriteRune('?')
 			}
@@ -93,6 +526,16 @@ func DebugSQLWithBinds(sql string, binds []interface{}) string {
 	return sb.String()
 }
 
-func trimEndSymbol(out string) string {
-	return ReGTrim.ReplaceAllString(out, "")
+func debugValue(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		// 字符串加单引号，并替换内部单引号为双单引号
+		escaped := strings.ReplaceAll(v, "'", "''")
+
+		return "'" + escaped + "'"
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprintf("%v", v)
+	}
 }
@@ -0,0 +1,394 @@
+package gsql
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/chunhui2001/zero4go/pkg/gredis"
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/utils"
+)
+
+const cacheKeyPrefix = "gsql:cache:"
+
+type skipCacheKey struct{}
+
+// SkipCache 本次查询不读取也不写入缓存
+func SkipCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipCacheKey{}, true)
+}
+
+func skipCache(ctx context.Context) bool {
+	v, _ := ctx.Value(skipCacheKey{}).(bool)
+
+	return v
+}
+
+// queryCache 查询结果缓存: 本地 LRU + Redis 两级, 只缓存 MYSQL_CACHE_TEMPLATES 中声明了 TTL 的模板;
+// 写模板执行成功后, 按 MYSQL_CACHE_TABLES 中的表标签清除相同表的查询缓存
+type queryCache struct {
+	name     string
+	ttl      map[string]time.Duration // 模板 → TTL
+	tables   map[string][]string      // 模板 → 表
+	local    *lru.Cache[string, cacheEntry]
+	localTTL time.Duration
+
+	mu sync.Mutex // 保护 local 的按表清除
+}
+
+type cacheEntry struct {
+	data     []byte
+	expireAt time.Time
+}
+
+// setupCache 解析缓存配置, 未声明任何模板时不启用缓存
+func (s *MySQLClient) setupCache() {
+	ttl := parseTemplateMap(s.conf.CacheTemplates)
+
+	if len(ttl) == 0 {
+		return
+	}
+
+	c := &queryCache{
+		name:     s.conf.Name,
+		ttl:      make(map[string]time.Duration, len(ttl)),
+		tables:   make(map[string][]string),
+		localTTL: s.conf.CacheLocalTTL,
+	}
+
+	for tplName, v := range ttl {
+		d, err := time.ParseDuration(v)
+
+		if err != nil || d <= 0 {
+			Log.Warnf("MySQL-Cache-Invalid-TTL: DataSource=%s, tplName=%s, ttl=%s", s.conf.Name, tplName, v)
+
+			continue
+		}
+
+		c.ttl[tplName] = d
+	}
+
+	for tplName, v := range parseTemplateMap(s.conf.CacheTables) {
+		for _, table := range strings.Split(v, "|") {
+			if table = strings.TrimSpace(table); table != "" {
+				c.tables[tplName] = append(c.tables[tplName], table)
+			}
+		}
+	}
+
+	if s.conf.CacheLocalSize > 0 && s.conf.CacheLocalTTL > 0 {
+		c.local, _ = lru.New[string, cacheEntry](s.conf.CacheLocalSize)
+	}
+
+	s.cache = c
+
+	Log.Infof("MySQL-Cache-Enabled: DataSource=%s, Templates=%d, LocalSize=%d, LocalTTL=%s, Redis=%t", s.conf.Name, len(c.ttl), s.conf.CacheLocalSize, s.conf.CacheLocalTTL, gredis.RedisClient != nil)
+}
+
+// parseTemplateMap 解析 "order_select.txt=30s,order_select_ids.txt=1m" 形式的配置
+func parseTemplateMap(s string) map[string]string {
+	out := make(map[string]string)
+
+	for _, item := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(item, "=")
+
+		if k = strings.TrimSpace(k); ok && k != "" {
+			out[k] = strings.TrimSpace(v)
+		}
+	}
+
+	return out
+}
+
+// cachedQuery 模板声明了 TTL 时先查缓存, 未命中再执行 query 并写入缓存; 缓存读写失败只记录日志, 不影响查询
+func cachedQuery[V any](ctx context.Context, _client *MySQLClient, tplName string, sqlStr string, binds []any, query func() (V, error)) (V, error) {
+	c := _client.cache
+
+	if c == nil || skipCache(ctx) {
+		return query()
+	}
+
+	ttl, ok := c.ttl[tplName]
+
+	if !ok || !cacheable(utils.TypeOf[V](), tplName, c.name) {
+		return query()
+	}
+
+	key, err := c.key(tplName, sqlStr, binds)
+
+	if err != nil {
+		return query()
+	}
+
+	if data, ok := c.get(ctx, key); ok {
+		var v V
+
+		if err := json.Unmarshal(data, &v); err == nil {
+			return v, nil
+		}
+	}
+
+	v, err := query()
+
+	if err != nil {
+		return v, err
+	}
+
+	if data, err := json.Marshal(v); err == nil {
+		c.set(ctx, tplName, key, data, ttl)
+	}
+
+	return v, nil
+}
+
+// 每个类型只检查一次
+var cacheableTypes sync.Map // reflect.Type → bool
+
+// cacheable 结果以 JSON 缓存, 含 interface (如 map[string]any) 的类型读出后类型会改变 (int64 → float64, time.Time → string),
+// 不缓存, 直接查询
+func cacheable(typ reflect.Type, tplName string, dataSource string) bool {
+	if v, ok := cacheableTypes.Load(typ); ok {
+		return v.(bool)
+	}
+
+	ok := !hasInterface(typ, map[reflect.Type]bool{})
+
+	if !ok {
+		Log.Warnf("MySQL-Cache-Skipped: DataSource=%s, tplName=%s, Type=%s, Error=type contains interface values, scan into a struct to enable caching", dataSource, tplName, typ)
+	}
+
+	cacheableTypes.Store(typ, ok)
+
+	return ok
+}
+
+func hasInterface(typ reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[typ] {
+		return false
+	}
+
+	seen[typ] = true
+
+	switch typ.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return hasInterface(typ.Elem(), seen)
+	case reflect.Map:
+		return hasInterface(typ.Key(), seen) || hasInterface(typ.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			if f := typ.Field(i); f.IsExported() && f.Tag.Get("json") != "-" && hasInterface(f.Type, seen) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// key gsql:cache:<数据源>:<模板>:<sha1(sql, binds)>
+func (c *queryCache) key(tplName string, sqlStr string, binds []any) (string, error) {
+	b, err := json.Marshal(binds)
+
+	if err != nil {
+		return "", err
+	}
+
+	h := sha1.New()
+	h.Write([]byte(sqlStr))
+	h.Write([]byte{0})
+	h.Write(b)
+
+	return cacheKeyPrefix + c.name + ":" + tplName + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *queryCache) tagKey(table string) string {
+	return cacheKeyPrefix + c.name + ":tag:" + table
+}
+
+func (c *queryCache) get(ctx context.Context, key string) ([]byte, bool) {
+	if c.local != nil {
+		if e, ok := c.local.Get(key); ok {
+			if time.Now().Before(e.expireAt) {
+				return e.data, true
+			}
+
+			c.local.Remove(key)
+		}
+	}
+
+	if gredis.RedisClient == nil {
+		return nil, false
+	}
+
+	data, err := gredis.RedisClient.Get(ctx, key).Bytes()
+
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			Log.Warnf("MySQL-Cache-Get-Error: DataSource=%s, Key=%s, Error=%s", c.name, key, err.Error())
+		}
+
+		return nil, false
+	}
+
+	if c.local != nil {
+		c.local.Add(key, cacheEntry{data: data, expireAt: time.Now().Add(c.localTTL)})
+	}
+
+	return data, true
+}
+
+func (c *queryCache) set(ctx context.Context, tplName string, key string, data []byte, ttl time.Duration) {
+	if c.local != nil {
+		c.local.Add(key, cacheEntry{data: data, expireAt: time.Now().Add(c.localTTLOf(ttl))})
+	}
+
+	if gredis.RedisClient == nil {
+		return
+	}
+
+	pipe := gredis.RedisClient.TxPipeline()
+
+	pipe.Set(ctx, key, data, ttl)
+
+	// 表标签: 记录该表相关的缓存 key, 写操作时据此清除
+	for _, table := range c.tables[tplName] {
+		pipe.SAdd(ctx, c.tagKey(table), key)
+		pipe.Expire(ctx, c.tagKey(table), c.maxTTL())
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		Log.Warnf("MySQL-Cache-Set-Error: DataSource=%s, Key=%s, Error=%s", c.name, key, err.Error())
+	}
+}
+
+// localTTLOf 本地缓存无法感知其他节点的写操作, 其 TTL 不超过 MYSQL_CACHE_LOCAL_TTL
+func (c *queryCache) localTTLOf(ttl time.Duration) time.Duration {
+	if c.localTTL < ttl {
+		return c.localTTL
+	}
+
+	return ttl
+}
+
+func (c *queryCache) maxTTL() time.Duration {
+	var max time.Duration
+
+	for _, ttl := range c.ttl {
+		if ttl > max {
+			max = ttl
+		}
+	}
+
+	return max
+}
+
+// invalidate 清除 tables 相关的查询缓存
+func (c *queryCache) invalidate(ctx context.Context, tables []string) {
+	if len(tables) == 0 {
+		return
+	}
+
+	if c.local != nil {
+		c.purgeLocal(tables)
+	}
+
+	if gredis.RedisClient == nil {
+		return
+	}
+
+	for _, table := range tables {
+		tag := c.tagKey(table)
+
+		keys, err := gredis.RedisClient.SMembers(ctx, tag).Result()
+
+		if err != nil {
+			Log.Warnf("MySQL-Cache-Invalidate-Error: DataSource=%s, Table=%s, Error=%s", c.name, table, err.Error())
+
+			continue
+		}
+
+		if err := gredis.RedisClient.Del(ctx, append(keys, tag)...).Err(); err != nil {
+			Log.Warnf("MySQL-Cache-Invalidate-Error: DataSource=%s, Table=%s, Error=%s", c.name, table, err.Error())
+
+			continue
+		}
+
+		Log.Debugf("MySQL-Cache-Invalidated: DataSource=%s, Table=%s, Keys=%d", c.name, table, len(keys))
+	}
+}
+
+// purgeLocal 按 key 中的模板名找出标记了相同表的本地缓存并删除
+func (c *queryCache) purgeLocal(tables []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var prefix = cacheKeyPrefix + c.name + ":"
+
+	for _, key := range c.local.Keys() {
+		tplName, _, _ := strings.Cut(strings.TrimPrefix(key, prefix), ":")
+
+		if intersects(c.tables[tplName], tables) {
+			c.local.Remove(key)
+		}
+	}
+}
+
+func intersects(a []string, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// invalidateTemplates 写模板执行成功后, 清除与其标记了相同表的查询缓存
+func (s *MySQLClient) invalidateTemplates(ctx context.Context, tplNames ...string) {
+	if s.cache == nil {
+		return
+	}
+
+	var tables []string
+
+	for _, tplName := range tplNames {
+		tables = append(tables, s.cache.tables[tplName]...)
+	}
+
+	s.cache.invalidate(ctx, tables)
+}
+
+func (s *MySQLClient) invalidateTable(ctx context.Context, table string) {
+	if s.cache != nil && table != "" {
+		s.cache.invalidate(ctx, []string{table})
+	}
+}
+
+// InvalidateCache 手动清除 tables 相关的查询缓存, 用于绕过 gsql 修改了数据的场景
+func InvalidateCache(ctx context.Context, dbname string, tables ...string) error {
+	_client, err := dataSource(dbname)
+
+	if err != nil {
+		return err
+	}
+
+	if _client.cache != nil {
+		_client.cache.invalidate(ctx, tables)
+	}
+
+	return nil
+}
@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"text/template"
-	"time"
+
+	"github.com/fsnotify/fsnotify"
 
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
 	"github.com/chunhui2001/zero4go/pkg/utils"
@@ -16,94 +20,220 @@ type MySQLClient struct {
 	//render *pongo2.TemplateSet
 	render *template.Template
 	conf   *MySQLConf
+
+	mu      sync.RWMutex // 保护 render, mapper 热加载时替换
+	watcher *fsnotify.Watcher
+
+	replicas []*replica
+	next     atomic.Uint64 // round_robin 计数
+
+	maxPacket atomic.Int64 // 缓存的 @@max_allowed_packet, BatchInsert 拆分语句时使用
+	autoIncOK atomic.Int32 // 缓存的多行 INSERT 是否分配连续 ID: 0 未检查, 1 是, 2 否
+
+	cache *queryCache // 查询结果缓存, 未配置 MYSQL_CACHE_TEMPLATES 时为 nil
+
+	stop      chan struct{} // 关闭时停止从库健康检查
+	closeOnce sync.Once
+}
+
+// sqlRunner *sql.DB 与 *sql.Tx 的公共部分, 模板化的增删改查在两者之上共用一套实现
+type sqlRunner interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
 }
 
-// RenderSQL 1️⃣ 渲染 SQL + 获取绑定值
+// RenderSQL 1️⃣ 渲染 SQL + 获取绑定值, 不应用乐观锁和软删除约定, 可用于任何模板
 func (s *MySQLClient) RenderSQL(tplName string, params map[string]interface{}) (string, []any, error) {
-	bindCtx := NewSqlBindContext()
+	sqlStr, binds, _, err := s.RenderSQLContext(context.Background(), "", tplName, params)
+
+	return sqlStr, binds, err
+}
+
+// RenderSQLContext 按 action (Select / Insert / Update / Delete) 渲染, 并应用乐观锁和软删除约定 (见 conventions),
+// action 为空时只渲染; versioned 表示本次更新启用了乐观锁
+func (s *MySQLClient) RenderSQLContext(ctx context.Context, action string, tplName string, params map[string]interface{}) (_ string, _ []any, versioned bool, err error) {
+	tpl := s.template()
+
+	if tpl.Lookup(tplName) == nil {
+		err = &Error{DataSource: s.conf.Name, TplName: tplName, Action: action, Kind: ErrTemplateNotFound}
+
+		Log.Errorf("RenderSQL: DataSource=%s, tplName=%s, Error=%s", s.conf.Name, tplName, err.Error())
+
+		return "", nil, false, err
+	}
+
+	extras, versioned, err := s.conventions(action, params)
+
+	if err != nil {
+		Log.Errorf("RenderSQL: DataSource=%s, tplName=%s, Error=%s", s.conf.Name, tplName, err.Error())
+
+		return "", nil, false, err
+	}
+
+	bindCtx := NewDialectBindContext(s.conf.dialect())
+	bindCtx.sensitive = s.conf.sensitiveNames()
 
 	params["CTX"] = bindCtx
 
 	var buf bytes.Buffer
-	err := s.render.ExecuteTemplate(&buf, tplName, params)
+	err = tpl.ExecuteTemplate(&buf, tplName, params)
 
 	if err != nil {
 		Log.Errorf("RenderSQL: DataSource=%s, tplName=%s, Error=%s", s.conf.Name, tplName, err.Error())
 
-		return "", nil, err
+		return "", nil, false, err
 	}
 
 	// 取出绑定值
 	binds := bindCtx.TakeBinds()
 
-	var out = buf.String()
+	guards, err := s.softDeleteGuards(ctx, action, bindCtx.softDeletes)
+
+	if err != nil {
+		Log.Errorf("RenderSQL: DataSource=%s, tplName=%s, Error=%s", s.conf.Name, tplName, err.Error())
+
+		return "", nil, false, err
+	}
+
+	extras.where = append(extras.where, guards...)
+
+	out, err := expandBlocksWith(buf.String(), extras)
+
+	if err == nil {
+		out = trimEndSymbol(out)
+	}
+
+	// 模板没有 set / where 块时无法加上版本条件, 报错而不是静默地不加锁
+	if err == nil && versioned && (extras.whereApplied == 0 || extras.setApplied == 0) {
+		err = fmt.Errorf("gsql: optimistic locking on %s requires {{ set }} and {{ where }} blocks", tplName)
+	}
+
+	// 模板没有 where 块时无法排除已软删除的行, 同样报错
+	if err == nil && len(guards) > 0 && extras.whereApplied == 0 {
+		err = fmt.Errorf("gsql: soft delete on %s requires a {{ where }} block", tplName)
+	}
+
+	if err == nil && action == "Delete" && len(guards) > 0 {
+		out, err = s.softDelete(out)
+	}
+
+	if err != nil {
+		Log.Errorf("RenderSQL: DataSource=%s, tplName=%s, Error=%s", s.conf.Name, tplName, err.Error())
 
-	var sqlStatement = trimEndSymbol(utils.NormalizeSpace(DebugSQLWithBinds(out, binds)))
+		return "", nil, false, err
+	}
+
+	var sqlStatement = utils.NormalizeSpace(DebugSQLWithBinds(out, s.maskBinds(params, binds)))
 
 	Log.Debugf("sqlStatement: DataSource=%s, tplName=%s, sql=%s", s.conf.Name, tplName, sqlStatement)
 
-	return trimEndSymbol(out), binds, nil
+	return out, binds, versioned, nil
 }
 
 func (s *MySQLClient) Version() (string, error) {
+	return s.VersionContext(context.Background())
+}
+
+func (s *MySQLClient) VersionContext(ctx context.Context) (string, error) {
+	ctx, cancelFunc := s.withTimeout(ctx)
+
+	defer cancelFunc()
+
 	var version string
-	err2 := s.QueryRow("SELECT VERSION()").Scan(&version)
+	err2 := s.QueryRowContext(ctx, s.conf.dialect().VersionSQL()).Scan(&version)
 
 	return version, err2
 }
 
+// withTimeout 调用方未设置 deadline 时, 使用数据源配置的默认超时 (MYSQL_QUERY_TIMEOUT)
+func (s *MySQLClient) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || s.conf.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, s.conf.Timeout)
+}
+
 func (s *MySQLClient) Insert(tplName string, params map[string]any) (int64, error) {
-	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
+	return s.InsertContext(context.Background(), tplName, params)
+}
 
-	defer cancelFunc()
+func (s *MySQLClient) Update(tplName string, params map[string]any) (int64, error) {
+	return s.UpdateContext(context.Background(), tplName, params)
+}
 
-	sqlStr, binds, err := s.RenderSQL(tplName, params)
+func (s *MySQLClient) Delete(tplName string, params map[string]any) (int64, error) {
+	return s.DeleteContext(context.Background(), tplName, params)
+}
 
-	if err != nil {
-		return 0, err
-	}
+func (s *MySQLClient) InsertContext(ctx context.Context, tplName string, params map[string]any) (int64, error) {
+	ctx, cancelFunc := s.withTimeout(ctx)
 
-	// prepare the statement
-	stmt, err := s.Prepare(sqlStr)
+	defer cancelFunc()
 
-	if err != nil {
-		Log.Errorf("MySQL-Insert-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", s.conf.Name, tplName, sqlStr, err.Error())
+	n, err := withRetry(ctx, s, "Insert", tplName, func() (int64, error) {
+		return s.execute(ctx, s.DB, "Insert", tplName, params)
+	})
 
-		return -1, err
+	if err == nil {
+		s.invalidateTemplates(ctx, tplName)
 	}
 
-	defer stmt.Close()
+	return n, err
+}
 
-	// format all args at once
-	result, err := stmt.ExecContext(ctx, binds...)
+func (s *MySQLClient) UpdateContext(ctx context.Context, tplName string, params map[string]any) (int64, error) {
+	ctx, cancelFunc := s.withTimeout(ctx)
 
-	if err != nil {
-		Log.Errorf("MySQL-Insert-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", s.conf.Name, tplName, sqlStr, err.Error())
+	defer cancelFunc()
+
+	n, err := withRetry(ctx, s, "Update", tplName, func() (int64, error) {
+		return s.execute(ctx, s.DB, "Update", tplName, params)
+	})
 
-		return -1, err
+	if err == nil {
+		s.invalidateTemplates(ctx, tplName)
 	}
 
-	return result.LastInsertId()
+	return n, err
 }
 
-func (s *MySQLClient) Update(tplName string, params map[string]any) (int64, error) {
-	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
+func (s *MySQLClient) DeleteContext(ctx context.Context, tplName string, params map[string]any) (int64, error) {
+	ctx, cancelFunc := s.withTimeout(ctx)
 
 	defer cancelFunc()
 
-	sqlStr, binds, err := s.RenderSQL(tplName, params)
+	n, err := withRetry(ctx, s, "Delete", tplName, func() (int64, error) {
+		return s.execute(ctx, s.DB, "Delete", tplName, params)
+	})
+
+	if err == nil {
+		s.invalidateTemplates(ctx, tplName)
+	}
+
+	return n, err
+}
+
+// execute 渲染并执行一条写语句; Insert 返回 LastInsertId, Update/Delete 返回 RowsAffected.
+// 违反唯一约束、死锁等可分类的驱动错误包装为 *Error
+func (s *MySQLClient) execute(ctx context.Context, runner sqlRunner, action string, tplName string, params map[string]any) (int64, error) {
+	sqlStr, binds, versioned, err := s.RenderSQLContext(ctx, action, tplName, params)
 
 	if err != nil {
 		return 0, err
 	}
 
+	ctx, done := s.observe(ctx, action, tplName, sqlStr, s.maskBinds(params, binds))
+
 	// prepare the statement
-	stmt, err := s.Prepare(sqlStr)
+	stmt, err := runner.PrepareContext(ctx, sqlStr)
 
 	if err != nil {
-		Log.Errorf("MySQL-Update-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", s.conf.Name, tplName, sqlStr, err.Error())
+		Log.Errorf("MySQL-%s-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", action, s.conf.Name, tplName, sqlStr, err.Error())
 
-		return -1, err
+		done(0, err)
+
+		return 0, s.wrapError(action, tplName, err)
 	}
 
 	defer stmt.Close()
@@ -112,77 +242,149 @@ func (s *MySQLClient) Update(tplName string, params map[string]any) (int64, erro
 	result, err := stmt.ExecContext(ctx, binds...)
 
 	if err != nil {
-		Log.Errorf("MySQL-Update-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", s.conf.Name, tplName, sqlStr, err.Error())
+		Log.Errorf("MySQL-%s-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", action, s.conf.Name, tplName, sqlStr, err.Error())
+
+		done(0, err)
 
-		return -1, err
+		return 0, s.wrapError(action, tplName, err)
 	}
 
-	return result.RowsAffected()
-}
+	affected, err := result.RowsAffected()
 
-func (s *MySQLClient) Delete(tplName string, params map[string]any) (int64, error) {
-	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
+	done(affected, err)
 
-	defer cancelFunc()
+	if err != nil {
+		Log.Errorf("MySQL-%s-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", action, s.conf.Name, tplName, sqlStr, err.Error())
 
-	sqlStr, binds, err := s.RenderSQL(tplName, params)
+		return 0, s.wrapError(action, tplName, err)
+	}
 
-	if err != nil {
-		return 0, err
+	// PostgreSQL 驱动不支持 LastInsertId, 需要 ID 时使用 INSERT ... RETURNING 和 SelectRow
+	if action == "Insert" && s.conf.dialect().Name() != "postgres" {
+		return result.LastInsertId()
 	}
 
-	// prepare the statement
-	stmt, err := s.Prepare(sqlStr)
+	if versioned && affected == 0 {
+		Log.Warnf("MySQL-%s-Stale: DataSource=%s, tplName=%s, %s=%v", action, s.conf.Name, tplName, s.conf.VersionColumn, params[s.conf.VersionColumn])
 
-	if err != nil {
-		Log.Errorf("MySQL-Delete-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", s.conf.Name, tplName, sqlStr, err.Error())
+		return 0, &Error{DataSource: s.conf.Name, TplName: tplName, Action: action, Kind: ErrStaleUpdate}
+	}
+
+	return affected, nil
+}
+
+// renderSelect 渲染查询语句, ctx 中的 WithDeleted 决定是否排除软删除的行
+func (s *MySQLClient) renderSelect(ctx context.Context, tplName string, params map[string]any) (string, []any, error) {
+	sqlStr, binds, _, err := s.RenderSQLContext(ctx, "Select", tplName, params)
 
-		return -1, err
+	return sqlStr, binds, err
+}
+
+// dataSource 按名称查找数据源; dbname 为空或为默认数据源的名称时使用 Client, 其他找不到的名称返回 ErrUnknownDataSource
+func dataSource(dbname string) (*MySQLClient, error) {
+	if c := DataSouces[dbname]; c != nil {
+		return c, nil
 	}
 
-	defer stmt.Close()
+	if Client.render != nil && (dbname == "" || dbname == Client.conf.Name) {
+		return &Client, nil
+	}
 
-	// format all args at once
-	result, err := stmt.ExecContext(ctx, binds...)
+	return nil, fmt.Errorf("%w: %q", ErrUnknownDataSource, dbname)
+}
+
+func SelectRow[T any](dbname string, tplName string, params map[string]any) (*T, error) {
+	return SelectRowContext[T](context.Background(), dbname, tplName, params)
+}
+
+func SelectRows[T any](dbname string, tplName string, params map[string]any) ([]T, error) {
+	return SelectRowsContext[T](context.Background(), dbname, tplName, params)
+}
+
+// SelectRowContext 同 SelectRow, ctx 取消 (如 HTTP 请求中断, gRPC deadline) 时查询随之取消;
+// 没有查到数据时返回 ErrNoRows
+func SelectRowContext[T any](ctx context.Context, dbname string, tplName string, params map[string]any) (*T, error) {
+	_client, err := dataSource(dbname)
 
 	if err != nil {
-		Log.Errorf("MySQL-Delete-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", s.conf.Name, tplName, sqlStr, err.Error())
+		return nil, err
+	}
+
+	ctx, cancelFunc := _client.withTimeout(ctx)
+
+	defer cancelFunc()
+
+	sqlStr, binds, err := _client.renderSelect(ctx, tplName, params)
 
-		return -1, err
+	if err != nil {
+		return nil, err
 	}
 
-	return result.RowsAffected()
+	return cachedQuery(ctx, _client, tplName, sqlStr, binds, func() (*T, error) {
+		return queryRow[T](ctx, _client.reader(ctx), _client, tplName, params, sqlStr, binds)
+	})
 }
 
-func SelectRow[T any](dbname string, tplName string, params map[string]any) (*T, error) {
-	var _client *MySQLClient
+func SelectRowsContext[T any](ctx context.Context, dbname string, tplName string, params map[string]any) ([]T, error) {
+	_client, err := dataSource(dbname)
 
-	if DataSouces[dbname] != nil {
-		_client = DataSouces[dbname]
-	} else {
-		_client = &Client
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancelFunc := _client.withTimeout(ctx)
+
+	defer cancelFunc()
+
+	sqlStr, binds, err := _client.renderSelect(ctx, tplName, params)
+
+	if err != nil {
+		return nil, err
 	}
 
+	return cachedQuery(ctx, _client, tplName, sqlStr, binds, func() ([]T, error) {
+		return queryRows[T](ctx, _client.reader(ctx), _client, tplName, params, sqlStr, binds)
+	})
+}
+
+func selectRow[T any](ctx context.Context, runner sqlRunner, _client *MySQLClient, tplName string, params map[string]any) (*T, error) {
 	// 1️⃣ 渲染 SQL + 获取绑定值
-	sqlStr, binds, err := _client.RenderSQL(tplName, params)
+	sqlStr, binds, err := _client.renderSelect(ctx, tplName, params)
 
 	if err != nil {
 		return nil, err
 	}
 
+	return queryRow[T](ctx, runner, _client, tplName, params, sqlStr, binds)
+}
+
+// queryRow 执行已渲染的查询, 返回第一行
+func queryRow[T any](ctx context.Context, runner sqlRunner, _client *MySQLClient, tplName string, params map[string]any, sqlStr string, binds []any) (_ *T, err error) {
+	var count int64
+
+	ctx, done := _client.observe(ctx, "SelectRow", tplName, sqlStr, _client.maskBinds(params, binds))
+
+	defer func() { done(count, err) }()
+
 	// 4️⃣ 获取列名
-	rows, err := _client.Query(sqlStr, binds...)
+	rows, err := runner.QueryContext(ctx, sqlStr, binds...)
 
 	if err != nil {
 		Log.Errorf("MySQL-SelectRow-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", _client.conf.Name, tplName, sqlStr, err.Error())
 
-		return nil, err
+		return nil, _client.wrapError("SelectRow", tplName, err)
 	}
 
 	defer rows.Close()
 
 	if !rows.Next() {
-		return nil, nil
+		if err = rows.Err(); err != nil {
+			Log.Errorf("MySQL-SelectRow-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", _client.conf.Name, tplName, sqlStr, err.Error())
+
+			return nil, _client.wrapError("SelectRow", tplName, err)
+		}
+
+		return nil, ErrNoRows
 	}
 
 	cols, err := rows.Columns()
@@ -193,7 +395,7 @@ func SelectRow[T any](dbname string, tplName string, params map[string]any) (*T,
 		return nil, err
 	}
 
-	decoder, err := NewRowDecoder[T](cols)
+	decoder, err := newRowDecoder[T](cols, _client.conf.Strict)
 
 	if err != nil {
 		return nil, err
@@ -206,32 +408,37 @@ func SelectRow[T any](dbname string, tplName string, params map[string]any) (*T,
 		return nil, err
 	}
 
+	count = 1
+
 	return &result, nil
 }
 
-func SelectRows[T any](dbname string, tplName string, params map[string]any) ([]T, error) {
-	var _client *MySQLClient
-
-	if DataSouces[dbname] != nil {
-		_client = DataSouces[dbname]
-	} else {
-		_client = &Client
-	}
-
+func selectRows[T any](ctx context.Context, runner sqlRunner, _client *MySQLClient, tplName string, params map[string]any) ([]T, error) {
 	// 1️⃣ 渲染 SQL + 获取绑定值
-	sqlStr, binds, err := _client.RenderSQL(tplName, params)
+	sqlStr, binds, err := _client.renderSelect(ctx, tplName, params)
 
 	if err != nil {
 		return nil, err
 	}
 
+	return queryRows[T](ctx, runner, _client, tplName, params, sqlStr, binds)
+}
+
+// queryRows 执行已渲染的查询, 返回全部行
+func queryRows[T any](ctx context.Context, runner sqlRunner, _client *MySQLClient, tplName string, params map[string]any, sqlStr string, binds []any) (_ []T, err error) {
+	var results = make([]T, 0)
+
+	ctx, done := _client.observe(ctx, "SelectRows", tplName, sqlStr, _client.maskBinds(params, binds))
+
+	defer func() { done(int64(len(results)), err) }()
+
 	// 2️⃣ 执行查询
-	rows, err := _client.Query(sqlStr, binds...)
+	rows, err := runner.QueryContext(ctx, sqlStr, binds...)
 
 	if err != nil {
 		Log.Errorf("MySQL-SelectRows-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", _client.conf.Name, tplName, sqlStr, err.Error())
 
-		return nil, err
+		return nil, _client.wrapError("SelectRows", tplName, err)
 	}
 
 	defer rows.Close()
@@ -245,14 +452,12 @@ func SelectRows[T any](dbname string, tplName string, params map[string]any) ([]
 		return nil, err
 	}
 
-	decoder, err := NewRowDecoder[T](cols)
+	decoder, err := newRowDecoder[T](cols, _client.conf.Strict)
 
 	if err != nil {
 		return nil, err
 	}
 
-	var results = make([]T, 0)
-
 	for rows.Next() {
 		v, err := decoder.Decode(rows, cols)
 
@@ -263,5 +468,11 @@ func SelectRows[T any](dbname string, tplName string, params map[string]any) ([]
 		results = append(results, v)
 	}
 
+	if err = rows.Err(); err != nil {
+		Log.Errorf("MySQL-SelectRows-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", _client.conf.Name, tplName, sqlStr, err.Error())
+
+		return nil, _client.wrapError("SelectRows", tplName, err)
+	}
+
 	return results, nil
 }
@@ -0,0 +1,131 @@
+package gsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+// ErrStaleUpdate 乐观锁更新时没有匹配的行: 该行已被其他事务修改 (版本号不一致) 或已删除
+var ErrStaleUpdate = errors.New("gsql: stale update, the row was modified or deleted concurrently")
+
+// 单表 DELETE (表名后直接是 WHERE 或语句结束), 软删除时改写为 UPDATE
+var reDeleteFrom = regexp.MustCompile(`(?is)^(\s*)DELETE\s+FROM\s+([^\s;]+)(\s+WHERE\b|\s*;?\s*$)`)
+
+type withDeletedKey struct{}
+
+// WithDeleted 查询 (及更新) 包含已软删除的行; 对 Delete 无效, 已软删除的行不会再次更新删除时间
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withDeletedKey{}, true)
+}
+
+type hardDeleteKey struct{}
+
+// WithHardDelete 启用软删除时, 本次 Delete 仍然物理删除, 用于清理历史数据
+func WithHardDelete(ctx context.Context) context.Context {
+	return context.WithValue(ctx, hardDeleteKey{}, true)
+}
+
+func ctxFlag(ctx context.Context, key any) bool {
+	v, _ := ctx.Value(key).(bool)
+
+	return v
+}
+
+// conventions 按 MYSQL_VERSION_COLUMN 生成需要追加到最外层 where / set 块的内容:
+// 更新时 params 中包含版本列 (键名与列名相同), where 追加 <版本列> = <版本号>, set 追加 <版本列> = <版本列> + 1;
+// 返回的 versioned 表示本次更新启用了乐观锁
+func (s *MySQLClient) conventions(action string, params map[string]any) (*blockExtras, bool, error) {
+	var extras = &blockExtras{}
+	var d = s.conf.dialect()
+
+	if action != "Update" || s.conf.VersionColumn == "" {
+		return extras, false, nil
+	}
+
+	v, ok := params[s.conf.VersionColumn]
+
+	if !ok || v == nil {
+		return extras, false, nil
+	}
+
+	version, err := versionOf(v)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	col := quoteIdentOf(d, s.conf.VersionColumn)
+
+	// 版本号只接受整数, 直接写入 SQL, 不影响模板中其他绑定值的顺序
+	extras.where = append(extras.where, col+" = "+version)
+	extras.set = append(extras.set, col+" = "+col+" + 1")
+
+	return extras, true, nil
+}
+
+func versionOf(v any) (string, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	}
+
+	return "", &BindError{Func: "version", Msg: fmt.Sprintf("version must be an integer, got %T", v)}
+}
+
+// softDeleteGuards 模板以 {{ soft_delete "别名" .CTX }} 声明的表, 在最外层 where 块追加 <别名>.<软删除列> IS NULL:
+//   - 查询和更新: WithDeleted 时不追加
+//   - 删除: 始终追加 (不受 WithDeleted 影响, 避免覆盖已删除行的删除时间), 语句由 softDelete 改写为 UPDATE; WithHardDelete 时不做处理
+//
+// 没有声明的表不受影响; 声明了但未配置 MYSQL_SOFT_DELETE_COLUMN 时返回错误, 避免 Delete 误物理删除
+func (s *MySQLClient) softDeleteGuards(ctx context.Context, action string, aliases []string) ([]string, error) {
+	if len(aliases) == 0 || action == "" || action == "Insert" {
+		return nil, nil
+	}
+
+	if s.conf.SoftDeleteColumn == "" {
+		return nil, fmt.Errorf("gsql: {{ soft_delete }} requires MYSQL_SOFT_DELETE_COLUMN")
+	}
+
+	if action == "Delete" && ctxFlag(ctx, hardDeleteKey{}) {
+		return nil, nil
+	}
+
+	if action != "Delete" && ctxFlag(ctx, withDeletedKey{}) {
+		return nil, nil
+	}
+
+	var d = s.conf.dialect()
+	var guards = make([]string, 0, len(aliases))
+
+	for _, alias := range aliases {
+		col := s.conf.SoftDeleteColumn
+
+		if alias != "" {
+			col = alias + "." + col
+		}
+
+		guards = append(guards, quoteIdentOf(d, col)+" IS NULL")
+	}
+
+	return guards, nil
+}
+
+// softDelete 把单表 DELETE 改写为 UPDATE <表> SET <软删除列> = CURRENT_TIMESTAMP;
+// 无法改写的语句 (多表删除, 带别名等) 返回错误, 避免误物理删除
+func (s *MySQLClient) softDelete(sqlStr string) (string, error) {
+	if !reDeleteFrom.MatchString(sqlStr) {
+		return "", fmt.Errorf("gsql: soft delete requires a single-table DELETE FROM statement, use WithHardDelete to delete physically")
+	}
+
+	col := quoteIdentOf(s.conf.dialect(), s.conf.SoftDeleteColumn)
+
+	return reDeleteFrom.ReplaceAllString(sqlStr, "${1}UPDATE ${2} SET "+col+" = CURRENT_TIMESTAMP${3}"), nil
+}
@@ -0,0 +1,171 @@
+package gsql
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"maps"
+
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+)
+
+// IterRows 以游标方式逐行解码查询结果, 不把整个结果集缓存在内存中, 适用于导出等大结果集场景
+//
+//	for order, err := range gsql.IterRows[Order](ctx, "zero4rs_db", "order_select.txt", params) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// 迭代期间一直占用一个连接, 因此不使用数据源的默认超时, 生命周期由调用方的 ctx 控制
+func IterRows[T any](ctx context.Context, dbname string, tplName string, params map[string]any) iter.Seq2[T, error] {
+	_client, err := dataSource(dbname)
+
+	if err != nil {
+		return func(yield func(T, error) bool) {
+			var zero T
+
+			yield(zero, err)
+		}
+	}
+
+	return iterRows[T](ctx, _client.reader(ctx), _client, tplName, params)
+}
+
+func TxIterRows[T any](tx *Tx, tplName string, params map[string]any) iter.Seq2[T, error] {
+	return iterRows[T](tx.ctx, tx.tx, tx.client, tplName, params)
+}
+
+// SelectKeyset 键集分页: 每页以 limit=pageSize 重新渲染模板, 并把上一页最后一行的键 (keyOf) 写入 params[keyParam],
+// 直到某一页不足 pageSize 行为止. 模板需按该键升序排序, 如 order_select_page.txt 中的 f_id > ? ... limit ?
+//
+// 每页是一次独立的查询, 各自使用数据源的默认超时; pageSize 必须大于 0
+func SelectKeyset[T any](ctx context.Context, dbname string, tplName string, params map[string]any, keyParam string, pageSize int, keyOf func(T) any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		if pageSize <= 0 {
+			yield(zero, fmt.Errorf("gsql: SelectKeyset requires pageSize > 0, got %d", pageSize))
+
+			return
+		}
+
+		_client, err := dataSource(dbname)
+
+		if err != nil {
+			yield(zero, err)
+
+			return
+		}
+
+		// 不修改调用方的 params
+		params = maps.Clone(params)
+
+		if params == nil {
+			params = make(map[string]any)
+		}
+
+		for {
+			params["limit"] = pageSize
+
+			pageCtx, cancelFunc := _client.withTimeout(ctx)
+			page, err := selectRows[T](pageCtx, _client.reader(ctx), _client, tplName, params)
+			cancelFunc()
+
+			if err != nil {
+				yield(zero, err)
+
+				return
+			}
+
+			for _, v := range page {
+				if !yield(v, nil) {
+					return
+				}
+			}
+
+			if len(page) < pageSize {
+				return
+			}
+
+			params[keyParam] = keyOf(page[len(page)-1])
+		}
+	}
+}
+
+func iterRows[T any](ctx context.Context, runner sqlRunner, _client *MySQLClient, tplName string, params map[string]any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		// 1️⃣ 渲染 SQL + 获取绑定值
+		sqlStr, binds, err := _client.renderSelect(ctx, tplName, params)
+
+		if err != nil {
+			yield(zero, err)
+
+			return
+		}
+
+		var count int64
+
+		ctx, done := _client.observe(ctx, "IterRows", tplName, sqlStr, _client.maskBinds(params, binds))
+
+		defer func() { done(count, err) }()
+
+		// 2️⃣ 执行查询
+		rows, err := runner.QueryContext(ctx, sqlStr, binds...)
+
+		if err != nil {
+			Log.Errorf("MySQL-IterRows-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", _client.conf.Name, tplName, sqlStr, err.Error())
+
+			yield(zero, _client.wrapError("IterRows", tplName, err))
+
+			return
+		}
+
+		defer rows.Close()
+
+		// 3️⃣ 获取列名
+		cols, err := rows.Columns()
+
+		if err != nil {
+			Log.Errorf("MySQL-IterRows-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", _client.conf.Name, tplName, sqlStr, err.Error())
+
+			yield(zero, err)
+
+			return
+		}
+
+		decoder, err := newRowDecoder[T](cols, _client.conf.Strict)
+
+		if err != nil {
+			yield(zero, err)
+
+			return
+		}
+
+		// 4️⃣ 逐行解码
+		for rows.Next() {
+			var v T
+
+			if v, err = decoder.Decode(rows, cols); err != nil {
+				yield(zero, err)
+
+				return
+			}
+
+			count++
+
+			if !yield(v, nil) {
+				return
+			}
+		}
+
+		if err = rows.Err(); err != nil {
+			Log.Errorf("MySQL-IterRows-Error: DataSource=%s, tplName=%s, sqlStr=%s, Error=%s", _client.conf.Name, tplName, sqlStr, err.Error())
+
+			yield(zero, _client.wrapError("IterRows", tplName, err))
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package gsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+)
+
+var (
+	// ErrNoRows SelectRow 没有查到数据, 与 sql.ErrNoRows 相同
+	ErrNoRows = sql.ErrNoRows
+	// ErrUnknownDataSource 数据源不存在或未初始化
+	ErrUnknownDataSource = errors.New("gsql: unknown datasource")
+	// ErrTemplateNotFound mapper 模板不存在
+	ErrTemplateNotFound = errors.New("gsql: template not found")
+	// ErrDuplicateKey 违反唯一约束
+	ErrDuplicateKey = errors.New("gsql: duplicate key")
+	// ErrDeadlock 死锁, 事务已被数据库回滚
+	ErrDeadlock = errors.New("gsql: deadlock")
+	// ErrLockWaitTimeout 等待行锁超时
+	ErrLockWaitTimeout = errors.New("gsql: lock wait timeout")
+)
+
+// MySQL 错误码
+const (
+	erDupEntry        = 1062
+	erLockWaitTimeout = 1205
+	erLockDeadlock    = 1213
+)
+
+// Error 数据库错误及其分类: errors.Is(err, gsql.ErrDuplicateKey) 判断分类,
+// errors.As(err, &mysqlErr) 仍可取得驱动的原始错误
+type Error struct {
+	DataSource string
+	TplName    string
+	Action     string
+	Kind       error // ErrDuplicateKey / ErrDeadlock / ErrLockWaitTimeout / ErrTemplateNotFound / ErrStaleUpdate
+	Err        error // 驱动返回的原始错误
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s: DataSource=%s, tplName=%s", e.Kind.Error(), e.DataSource, e.TplName)
+	}
+
+	return fmt.Sprintf("%s: DataSource=%s, tplName=%s, Action=%s: %s", e.Kind.Error(), e.DataSource, e.TplName, e.Action, e.Err.Error())
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+
+	return []error{e.Kind, e.Err}
+}
+
+// sqlStateError PostgreSQL 驱动 (lib/pq, pgx) 的错误实现了 SQLState
+type sqlStateError interface {
+	SQLState() string
+}
+
+// kindOf 按 MySQL 错误码或 SQLSTATE 对驱动错误分类, 无法分类时返回 nil
+func kindOf(err error) error {
+	var myErr *mysql.MySQLError
+
+	if errors.As(err, &myErr) {
+		switch myErr.Number {
+		case erDupEntry:
+			return ErrDuplicateKey
+		case erLockDeadlock:
+			return ErrDeadlock
+		case erLockWaitTimeout:
+			return ErrLockWaitTimeout
+		}
+
+		return nil
+	}
+
+	var stateErr sqlStateError
+
+	if errors.As(err, &stateErr) {
+		switch stateErr.SQLState() {
+		case "23505":
+			return ErrDuplicateKey
+		case "40P01":
+			return ErrDeadlock
+		case "55P03":
+			return ErrLockWaitTimeout
+		}
+	}
+
+	return nil
+}
+
+// wrapError 可分类的驱动错误包装为 *Error, 其他错误原样返回
+func (s *MySQLClient) wrapError(action string, tplName string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if kind := kindOf(err); kind != nil {
+		return &Error{DataSource: s.conf.Name, TplName: tplName, Action: action, Kind: kind, Err: err}
+	}
+
+	return err
+}
+
+// retryable 死锁和锁等待超时可以重试
+func retryable(err error) bool {
+	return errors.Is(err, ErrDeadlock) || errors.Is(err, ErrLockWaitTimeout)
+}
+
+// withRetry 死锁或锁等待超时时重试 fn, 最多 MYSQL_DEADLOCK_RETRIES 次, 每次等待时间翻倍并加随机抖动;
+// 只用于可以整体重新执行的操作 (单条语句, 整个事务)
+func withRetry[V any](ctx context.Context, s *MySQLClient, action string, tplName string, fn func() (V, error)) (V, error) {
+	var backoff = s.conf.DeadlockBackoff
+
+	for attempt := 0; ; attempt++ {
+		v, err := fn()
+
+		if err == nil || attempt >= s.conf.DeadlockRetries || !retryable(err) {
+			return v, err
+		}
+
+		wait := backoff + time.Duration(rand.Int64N(int64(backoff)+1))
+
+		Log.Warnf("MySQL-%s-Retry: DataSource=%s, tplName=%s, Attempt=%d, Wait=%s, Error=%s", action, s.conf.Name, tplName, attempt+1, wait, err.Error())
+
+		select {
+		case <-ctx.Done():
+			return v, err
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+	}
+}
@@ -0,0 +1,110 @@
+package gsql
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/utils"
+)
+
+const maskedValue = "****"
+
+// QueryEvent 一次 SQL 执行的信息, Binds 中敏感参数已脱敏
+type QueryEvent struct {
+	DataSource string
+	DBSystem   string // mysql / postgres / sqlite
+	TplName    string
+	Action     string // Insert / Update / Delete / SelectRow / SelectRows / IterRows
+	SQL        string
+	Binds      []any
+	Rows       int64 // 影响或返回的行数
+	Start      time.Time
+	Duration   time.Duration
+	Err        error
+}
+
+// QueryHook 观察每一次 SQL 执行, 用于指标, 链路追踪等;
+// Before 返回的 ctx 会用于本次执行 (如携带 span), After 在执行结束后调用
+type QueryHook interface {
+	Before(ctx context.Context, e *QueryEvent) context.Context
+	After(ctx context.Context, e *QueryEvent)
+}
+
+var (
+	hooksMu sync.RWMutex
+	hooks   []QueryHook
+)
+
+// AddQueryHook 注册全局的 QueryHook, 对所有数据源生效
+func AddQueryHook(h QueryHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+
+	hooks = append(hooks, h)
+}
+
+func queryHooks() []QueryHook {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+
+	return hooks
+}
+
+// observe 开始计时并通知 hooks, 返回的 done 在执行结束后调用: 记录慢查询并通知 hooks
+func (s *MySQLClient) observe(ctx context.Context, action string, tplName string, sqlStr string, binds []any) (context.Context, func(rows int64, err error)) {
+	e := &QueryEvent{
+		DataSource: s.conf.Name,
+		DBSystem:   s.conf.dialect().Name(),
+		TplName:    tplName,
+		Action:     action,
+		SQL:        sqlStr,
+		Binds:      binds,
+		Start:      time.Now(),
+	}
+
+	hs := queryHooks()
+
+	for _, h := range hs {
+		ctx = h.Before(ctx, e)
+	}
+
+	return ctx, func(rows int64, err error) {
+		e.Rows = rows
+		e.Err = err
+		e.Duration = time.Since(e.Start)
+
+		if s.conf.SlowThreshold > 0 && e.Duration >= s.conf.SlowThreshold {
+			Log.Warnf("MySQL-Slow-Query: DataSource=%s, tplName=%s, Action=%s, Rows=%d, Duration=%s, sql=%s",
+				e.DataSource, e.TplName, e.Action, e.Rows, e.Duration, utils.NormalizeSpace(DebugSQLWithBinds(e.SQL, e.Binds)))
+		}
+
+		for _, h := range hs {
+			h.After(ctx, e)
+		}
+	}
+}
+
+// maskBinds 返回脱敏后的绑定值副本: 渲染时由 sql_bind_secret 等记录位置的绑定值被替换为 ****
+func (s *MySQLClient) maskBinds(params map[string]any, binds []any) []any {
+	if bindCtx, ok := params["CTX"].(*SqlBindContext); ok {
+		return bindCtx.mask(binds)
+	}
+
+	return binds
+}
+
+// sensitiveNames MYSQL_SENSITIVE_PARAMS 中的参数名, 按 normalizeName 比较 (FVipPhone 与 f_vip_phone 相同)
+func (conf *MySQLConf) sensitiveNames() map[string]bool {
+	names := map[string]bool{}
+
+	for _, name := range strings.Split(conf.SensitiveParams, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names[normalizeName(name)] = true
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,803 @@
+package gsql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/utils"
+)
+
+// 单条预编译语句最多 65535 个占位符
+const maxPlaceholders = 65535
+
+// 查询 @@max_allowed_packet 失败时使用的默认值 (MySQL 5.7 的默认值)
+const defaultMaxAllowedPacket = 4 << 20
+
+// Table 实现该接口的结构体以 TableName() 作为表名, 否则使用类型名的 snake_case, 如 OrderItem → order_item
+type Table interface {
+	TableName() string
+}
+
+type InsertOption func(*insertOptions)
+
+type insertOptions struct {
+	upsert     bool
+	updateCols []string
+	batchSize  int
+}
+
+// WithUpsert 生成 ON DUPLICATE KEY UPDATE, cols 为需要更新的列, 为空时更新除自增列外的所有列
+func WithUpsert(cols ...string) InsertOption {
+	return func(o *insertOptions) {
+		o.upsert = true
+		o.updateCols = cols
+	}
+}
+
+// WithBatchSize 每条 INSERT 语句最多包含的行数; 默认只受 max_allowed_packet 和占位符个数限制
+func WithBatchSize(n int) InsertOption {
+	return func(o *insertOptions) {
+		o.batchSize = n
+	}
+}
+
+type insertColumn struct {
+	name  string
+	index []int
+	json  bool // db:"col,json" 字段按 JSON 写入
+	auto  bool // db:"col,auto" 自增主键: 为零值时不写入, 插入后回填生成的 ID
+}
+
+type tableMeta struct {
+	table   string
+	columns []*insertColumn
+	auto    *insertColumn
+}
+
+// 每个类型的表名和列只解析一次
+var tableMetaCache sync.Map // reflect.Type → *tableMeta
+
+// tableMetaOf 只有带 db tag 的字段才会写入; 匿名嵌入的结构体展开, 非匿名的结构体字段以 "<tag>_" 为前缀展开
+func tableMetaOf(typ reflect.Type) (*tableMeta, error) {
+	if m, ok := tableMetaCache.Load(typ); ok {
+		return m.(*tableMeta), nil
+	}
+
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("gsql: insert requires a struct type, got %s", typ)
+	}
+
+	m := &tableMeta{table: tableNameOf(typ)}
+
+	m.collect(typ, nil, "", nil)
+
+	if len(m.columns) == 0 {
+		return nil, fmt.Errorf("gsql: %s has no field with db tag", typ)
+	}
+
+	actual, _ := tableMetaCache.LoadOrStore(typ, m)
+
+	return actual.(*tableMeta), nil
+}
+
+// tableOf T 对应的表名, T 不是结构体时返回空
+func tableOf[T any]() string {
+	if meta, err := tableMetaOf(utils.TypeOf[T]()); err == nil {
+		return meta.table
+	}
+
+	return ""
+}
+
+func tableNameOf(typ reflect.Type) string {
+	if t, ok := reflect.New(typ).Interface().(Table); ok {
+		return t.TableName()
+	}
+
+	return snakeCase(typ.Name())
+}
+
+// collect 同 structMeta.collect, 自引用的字段不再展开
+func (m *tableMeta) collect(typ reflect.Type, parent []int, prefix string, path []reflect.Type) {
+	path = append(path, typ)
+
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("db"), ",")
+
+		if name == "-" {
+			continue
+		}
+
+		index := append(append([]int{}, parent...), i)
+		ft := indirectType(f.Type)
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct && !isScanTarget(ft) {
+			if !slices.Contains(path, ft) {
+				m.collect(ft, index, prefix, path)
+			}
+
+			continue
+		}
+
+		if !f.IsExported() || name == "" {
+			continue
+		}
+
+		isJson := hasTagOpt(opts, "json")
+
+		if !isJson && ft.Kind() == reflect.Struct && !isScanTarget(ft) {
+			if !slices.Contains(path, ft) {
+				m.collect(ft, index, prefix+name+"_", path)
+			}
+
+			continue
+		}
+
+		col := &insertColumn{name: prefix + name, index: index, json: isJson, auto: hasTagOpt(opts, "auto")}
+
+		if col.auto && m.auto == nil {
+			m.auto = col
+		}
+
+		m.columns = append(m.columns, col)
+	}
+}
+
+// value 读取字段值, 途经 nil 指针时返回 nil (写入 NULL)
+func (c *insertColumn) value(row reflect.Value) (any, error) {
+	v := row
+
+	for i, x := range c.index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil, nil
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	if c.json {
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+			if v.IsNil() {
+				return nil, nil
+			}
+		}
+
+		data, err := json.Marshal(v.Interface())
+
+		if err != nil {
+			return nil, fmt.Errorf("gsql: marshal column %s: %w", c.name, err)
+		}
+
+		return string(data), nil
+	}
+
+	return v.Interface(), nil
+}
+
+func snakeCase(name string) string {
+	var sb strings.Builder
+
+	runes := []rune(name)
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// OrderID → order_id, HTTPServer → http_server
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				sb.WriteByte('_')
+			}
+
+			sb.WriteRune(unicode.ToLower(r))
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+// InsertStruct 按 db tag 插入一行, 返回 LastInsertId; 带 db:"col,auto" 的自增字段为零值时由数据库生成并回填
+// (PostgreSQL 不支持 LastInsertId, 返回 0, 需要 ID 时使用 RETURNING 模板和 SelectRow)
+//
+//	type Order struct {
+//		FID         int64  `db:"f_id,auto"`
+//		FWaiterName string `db:"f_waiter_name"`
+//	}
+//
+//	func (Order) TableName() string { return "t_orders" }
+//
+//	id, err := gsql.InsertStruct(ctx, "zero4rs_db", &order)
+func InsertStruct[T any](ctx context.Context, dbname string, row *T, opts ...InsertOption) (int64, error) {
+	_client, err := dataSource(dbname)
+
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancelFunc := _client.withTimeout(ctx)
+
+	defer cancelFunc()
+
+	id, err := withRetry(ctx, _client, "InsertStruct", tableOf[T](), func() (int64, error) {
+		return insertStruct(ctx, _client.DB, _client, row, opts)
+	})
+
+	if err == nil {
+		_client.invalidateTable(ctx, tableOf[T]())
+	}
+
+	return id, err
+}
+
+// BatchInsert 按 db tag 批量插入, 行数较多时按 max_allowed_packet 拆分为多条多行 INSERT, 多条语句在同一个事务中执行;
+// 返回每行生成的 ID 并回填到自增字段: PostgreSQL 使用 RETURNING; MySQL 依赖多行 INSERT 分配连续 ID,
+// 只在 innodb_autoinc_lock_mode 不为 2 且 auto_increment_increment 为 1 时回填, 否则返回 nil;
+// 使用 WithUpsert 或显式指定了自增字段的值时无法确定每行的 ID, 同样返回 nil
+func BatchInsert[T any](ctx context.Context, dbname string, rows []T, opts ...InsertOption) ([]int64, error) {
+	_client, err := dataSource(dbname)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancelFunc := _client.withTimeout(ctx)
+
+	defer cancelFunc()
+
+	plan, err := newInsertPlan[T](ctx, _client.DB, _client, rows, opts)
+
+	if err != nil || plan == nil {
+		return nil, err
+	}
+
+	var ids []int64
+
+	if len(plan.chunks) == 1 {
+		ids, err = withRetry(ctx, _client, "BatchInsert", plan.meta.table, func() ([]int64, error) {
+			return plan.exec(ctx, _client.DB, rows)
+		})
+	} else {
+		err = _client.Tx(ctx, func(tx *Tx) error {
+			ids, err = plan.exec(tx.ctx, tx.tx, rows)
+
+			return err
+		})
+	}
+
+	if err == nil {
+		_client.invalidateTable(ctx, plan.meta.table)
+	}
+
+	return ids, err
+}
+
+// TxInsertStruct 同 InsertStruct, 在事务中执行
+func TxInsertStruct[T any](tx *Tx, row *T, opts ...InsertOption) (int64, error) {
+	tx.touch(tableOf[T]())
+
+	return insertStruct(tx.ctx, tx.tx, tx.client, row, opts)
+}
+
+// TxBatchInsert 同 BatchInsert, 在事务中执行
+func TxBatchInsert[T any](tx *Tx, rows []T, opts ...InsertOption) ([]int64, error) {
+	plan, err := newInsertPlan[T](tx.ctx, tx.tx, tx.client, rows, opts)
+
+	if err != nil || plan == nil {
+		return nil, err
+	}
+
+	tx.touch(plan.meta.table)
+
+	return plan.exec(tx.ctx, tx.tx, rows)
+}
+
+func insertStruct[T any](ctx context.Context, runner sqlRunner, _client *MySQLClient, row *T, opts []InsertOption) (int64, error) {
+	if row == nil {
+		return 0, fmt.Errorf("gsql: InsertStruct row is nil")
+	}
+
+	rows := []T{*row}
+
+	plan, err := newInsertPlan[T](ctx, runner, _client, rows, opts)
+
+	if err != nil {
+		return 0, err
+	}
+
+	sqlStr, binds := plan.statement(plan.chunks[0])
+
+	result, returned, err := plan.run(ctx, runner, "InsertStruct", sqlStr, binds)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if plan.returning {
+		if len(returned) != 1 {
+			return 0, fmt.Errorf("gsql: InsertStruct expected 1 returned id, got %d", len(returned))
+		}
+
+		plan.setID(reflect.ValueOf(row).Elem(), returned[0])
+
+		return returned[0], nil
+	}
+
+	if !plan.hasLastID {
+		return 0, nil
+	}
+
+	id, err := result.LastInsertId()
+
+	if err == nil && plan.fillIDs {
+		plan.setID(reflect.ValueOf(row).Elem(), id)
+	}
+
+	return id, err
+}
+
+// insertPlan 一次批量插入的列和拆分结果, binds 与行一一对应
+type insertPlan struct {
+	client    *MySQLClient
+	meta      *tableMeta
+	options   insertOptions
+	columns   []*insertColumn
+	binds     [][]any
+	chunks    [][2]int // 每条语句包含的行 [from, to)
+	dialect   Dialect
+	hasLastID bool
+	returning bool // PostgreSQL 以 RETURNING 返回生成的 ID
+	fillIDs   bool
+}
+
+func newInsertPlan[T any](ctx context.Context, runner sqlRunner, _client *MySQLClient, rows []T, opts []InsertOption) (*insertPlan, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	meta, err := tableMetaOf(utils.TypeOf[T]())
+
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &insertPlan{client: _client, meta: meta, dialect: _client.conf.dialect()}
+
+	for _, opt := range opts {
+		opt(&plan.options)
+	}
+
+	if plan.options.upsert && plan.dialect.Name() != "mysql" {
+		return nil, fmt.Errorf("gsql: upsert is not supported by dialect %s", plan.dialect.Name())
+	}
+
+	// 自增字段全部为零值时不写入该列, 由数据库生成; 否则写入, 零值的行写入 NULL,
+	// MySQL 的 AUTO_INCREMENT 和 SQLite 的 rowid 遇到 NULL 时同样由数据库生成
+	var explicitID, zeroID = false, false
+
+	if meta.auto != nil {
+		for i := range rows {
+			if v, _ := meta.auto.value(reflect.ValueOf(&rows[i]).Elem()); !isZeroBind(v) {
+				explicitID = true
+			} else {
+				zeroID = true
+			}
+		}
+	}
+
+	// PostgreSQL 的 serial / identity 列为 NOT NULL, 写入 NULL 不会使用默认值
+	if explicitID && zeroID && plan.dialect.Name() == "postgres" {
+		return nil, fmt.Errorf("gsql: %s mixes rows with and without %s, which postgres cannot insert in one statement; insert them separately", meta.table, meta.auto.name)
+	}
+
+	for _, c := range meta.columns {
+		if !c.auto || explicitID {
+			plan.columns = append(plan.columns, c)
+		}
+	}
+
+	// 只有自增字段且全部为零值时没有可写入的列
+	if len(plan.columns) == 0 {
+		return nil, fmt.Errorf("gsql: %s has no column to insert besides the auto-increment field %s", meta.table, meta.auto.name)
+	}
+
+	// PostgreSQL 驱动不支持 LastInsertId, 改用 RETURNING
+	plan.hasLastID = plan.dialect.Name() != "postgres"
+	plan.fillIDs = meta.auto != nil && !explicitID && !plan.options.upsert
+	plan.returning = plan.fillIDs && !plan.hasLastID
+
+	// MySQL 多行 INSERT 只有一个 LastInsertId, 只有分配连续 ID 时才能推算每行的 ID
+	if plan.fillIDs && len(rows) > 1 && plan.dialect.Name() == "mysql" && !_client.consecutiveAutoInc(ctx, runner) {
+		plan.fillIDs = false
+	}
+
+	plan.binds = make([][]any, len(rows))
+
+	for i := range rows {
+		row := reflect.ValueOf(&rows[i]).Elem()
+
+		plan.binds[i] = make([]any, len(plan.columns))
+
+		for j, c := range plan.columns {
+			if plan.binds[i][j], err = c.value(row); err != nil {
+				return nil, err
+			}
+
+			if c.auto && isZeroBind(plan.binds[i][j]) {
+				plan.binds[i][j] = nil
+			}
+		}
+	}
+
+	plan.split(_client.maxAllowedPacket(ctx, runner))
+
+	return plan, nil
+}
+
+// split 按 max_allowed_packet 的一半估算每条语句的大小 (为字符串转义和协议开销留出余量), 同时不超过占位符上限和 batchSize
+func (p *insertPlan) split(maxPacket int64) {
+	var budget = maxPacket / 2
+	var maxRows = maxPlaceholders / len(p.columns)
+
+	if p.options.batchSize > 0 && p.options.batchSize < maxRows {
+		maxRows = p.options.batchSize
+	}
+
+	var from = 0
+	var size = int64(len(p.prefix()) + len(p.suffix()))
+
+	for i, binds := range p.binds {
+		rowSize := int64(len(p.columns)*3 + 4)
+
+		for _, b := range binds {
+			rowSize += bindSize(b)
+		}
+
+		if i > from && (i-from >= maxRows || size+rowSize > budget) {
+			p.chunks = append(p.chunks, [2]int{from, i})
+
+			from = i
+			size = int64(len(p.prefix()) + len(p.suffix()))
+		}
+
+		size += rowSize
+	}
+
+	p.chunks = append(p.chunks, [2]int{from, len(p.binds)})
+}
+
+func isZeroBind(b any) bool {
+	return b == nil || reflect.ValueOf(b).IsZero()
+}
+
+func bindSize(b any) int64 {
+	switch v := b.(type) {
+	case nil:
+		return 4
+	case string:
+		return int64(len(v)) + 2
+	case []byte:
+		return int64(len(v)) + 2
+	}
+
+	return 24
+}
+
+func (p *insertPlan) prefix() string {
+	names := make([]string, len(p.columns))
+
+	for i, c := range p.columns {
+		names[i] = quoteIdentOf(p.dialect, c.name)
+	}
+
+	return "INSERT INTO " + quoteIdentOf(p.dialect, p.meta.table) + " (" + strings.Join(names, ", ") + ") VALUES "
+}
+
+func (p *insertPlan) suffix() string {
+	if p.returning {
+		return " RETURNING " + quoteIdentOf(p.dialect, p.meta.auto.name)
+	}
+
+	if !p.options.upsert {
+		return ""
+	}
+
+	var cols = p.options.updateCols
+
+	if len(cols) == 0 {
+		for _, c := range p.columns {
+			if !c.auto {
+				cols = append(cols, c.name)
+			}
+		}
+	}
+
+	sets := make([]string, len(cols))
+
+	for i, c := range cols {
+		sets[i] = quoteIdentOf(p.dialect, c) + " = VALUES(" + quoteIdentOf(p.dialect, c) + ")"
+	}
+
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+func (p *insertPlan) statement(chunk [2]int) (string, []any) {
+	var sb strings.Builder
+	var binds []any
+
+	holders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(p.columns)), ", ") + ")"
+
+	sb.WriteString(p.prefix())
+
+	for i := chunk[0]; i < chunk[1]; i++ {
+		if i > chunk[0] {
+			sb.WriteString(", ")
+		}
+
+		sb.WriteString(holders)
+
+		binds = append(binds, p.binds[i]...)
+	}
+
+	sb.WriteString(p.suffix())
+
+	return rebind(p.dialect, sb.String()), binds
+}
+
+// exec 逐条执行拆分后的语句, 返回每行生成的 ID:
+// PostgreSQL 按 RETURNING 的顺序 (即 VALUES 的顺序); SQLite 同一时间只有一个写入, 一条语句的各行 rowid 连续, LastInsertId 为最后一行;
+// MySQL 的 LastInsertId 为第一行, 只在 consecutiveAutoInc 时推算其余行
+func (p *insertPlan) exec(ctx context.Context, runner sqlRunner, rows any) ([]int64, error) {
+	var ids []int64
+
+	if p.fillIDs {
+		ids = make([]int64, len(p.binds))
+	}
+
+	rv := reflect.ValueOf(rows)
+
+	for _, chunk := range p.chunks {
+		sqlStr, binds := p.statement(chunk)
+
+		result, returned, err := p.run(ctx, runner, "BatchInsert", sqlStr, binds)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if ids == nil {
+			continue
+		}
+
+		var n = chunk[1] - chunk[0]
+		var first int64
+
+		if p.returning {
+			if len(returned) != n {
+				return nil, fmt.Errorf("gsql: BatchInsert expected %d returned ids, got %d", n, len(returned))
+			}
+		} else {
+			if first, err = result.LastInsertId(); err != nil {
+				return nil, err
+			}
+
+			if p.dialect.Name() == "sqlite" {
+				first -= int64(n - 1)
+			}
+		}
+
+		for i := chunk[0]; i < chunk[1]; i++ {
+			if p.returning {
+				ids[i] = returned[i-chunk[0]]
+			} else {
+				ids[i] = first + int64(i-chunk[0])
+			}
+
+			p.setID(rv.Index(i), ids[i])
+		}
+	}
+
+	return ids, nil
+}
+
+// maskBinds 列名在 MYSQL_SENSITIVE_PARAMS 中 (按 normalizeName 比较) 的绑定值替换为 ****; binds 按行依次排列各列的值
+func (p *insertPlan) maskBinds(binds []any) []any {
+	sensitive := p.client.conf.sensitiveNames()
+
+	if len(sensitive) == 0 || len(p.columns) == 0 {
+		return binds
+	}
+
+	var out []any
+
+	for i := range binds {
+		if sensitive[normalizeName(p.columns[i%len(p.columns)].name)] {
+			if out == nil {
+				out = slices.Clone(binds)
+			}
+
+			out[i] = maskedValue
+		}
+	}
+
+	if out == nil {
+		return binds
+	}
+
+	return out
+}
+
+// run 执行一条 INSERT; returning 时返回 RETURNING 的 ID, result 为 nil
+func (p *insertPlan) run(ctx context.Context, runner sqlRunner, action string, sqlStr string, binds []any) (sql.Result, []int64, error) {
+	masked := p.maskBinds(binds)
+
+	ctx, done := p.client.observe(ctx, action, p.meta.table, sqlStr, masked)
+
+	Log.Debugf("sqlStatement: DataSource=%s, table=%s, sql=%s", p.client.conf.Name, p.meta.table, utils.NormalizeSpace(DebugSQLWithBinds(sqlStr, masked)))
+
+	stmt, err := runner.PrepareContext(ctx, sqlStr)
+
+	if err != nil {
+		Log.Errorf("MySQL-%s-Error: DataSource=%s, table=%s, Error=%s", action, p.client.conf.Name, p.meta.table, err.Error())
+
+		done(0, err)
+
+		return nil, nil, p.client.wrapError(action, p.meta.table, err)
+	}
+
+	defer stmt.Close()
+
+	if p.returning {
+		ids, err := returnedIDs(stmt.QueryContext(ctx, binds...))
+
+		if err != nil {
+			Log.Errorf("MySQL-%s-Error: DataSource=%s, table=%s, Error=%s", action, p.client.conf.Name, p.meta.table, err.Error())
+
+			done(0, err)
+
+			return nil, nil, p.client.wrapError(action, p.meta.table, err)
+		}
+
+		done(int64(len(ids)), nil)
+
+		return nil, ids, nil
+	}
+
+	result, err := stmt.ExecContext(ctx, binds...)
+
+	if err != nil {
+		Log.Errorf("MySQL-%s-Error: DataSource=%s, table=%s, Error=%s", action, p.client.conf.Name, p.meta.table, err.Error())
+
+		done(0, err)
+
+		return nil, nil, p.client.wrapError(action, p.meta.table, err)
+	}
+
+	affected, err := result.RowsAffected()
+
+	done(affected, err)
+
+	return result, nil, nil
+}
+
+func returnedIDs(rows *sql.Rows, err error) ([]int64, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var ids []int64
+
+	for rows.Next() {
+		var id int64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (p *insertPlan) setID(row reflect.Value, id int64) {
+	f := fieldByIndex(row, p.meta.auto.index)
+
+	if f.Kind() == reflect.Pointer {
+		f.Set(reflect.New(f.Type().Elem()))
+		f = f.Elem()
+	}
+
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f.SetInt(id)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f.SetUint(uint64(id))
+	}
+}
+
+// consecutiveAutoInc 读取并缓存 MySQL 的自增配置: 多行 INSERT 只有在 innodb_autoinc_lock_mode 为 0 / 1
+// (2 时并发插入的 ID 可能交错) 且 auto_increment_increment 为 1 时才分配从 LastInsertId 开始的连续 ID
+func (s *MySQLClient) consecutiveAutoInc(ctx context.Context, runner sqlRunner) bool {
+	if v := s.autoIncOK.Load(); v != 0 {
+		return v == 1
+	}
+
+	var lockMode, increment int64
+
+	rows, err := runner.QueryContext(ctx, "SELECT @@innodb_autoinc_lock_mode, @@auto_increment_increment")
+
+	if err != nil {
+		// 无法确认时不回填, 也不缓存, 下次再查询
+		Log.Warnf("MySQL-AutoIncrement-Error: DataSource=%s, Error=%s", s.conf.Name, err.Error())
+
+		return false
+	}
+
+	if rows.Next() {
+		err = rows.Scan(&lockMode, &increment)
+	}
+
+	_ = rows.Close()
+
+	if err != nil {
+		Log.Warnf("MySQL-AutoIncrement-Error: DataSource=%s, Error=%s", s.conf.Name, err.Error())
+
+		return false
+	}
+
+	if lockMode == 2 || increment != 1 {
+		Log.Warnf("MySQL-AutoIncrement-Not-Consecutive: DataSource=%s, innodb_autoinc_lock_mode=%d, auto_increment_increment=%d, BatchInsert will not return ids", s.conf.Name, lockMode, increment)
+
+		s.autoIncOK.Store(2)
+
+		return false
+	}
+
+	s.autoIncOK.Store(1)
+
+	return true
+}
+
+// maxAllowedPacket 读取并缓存服务端的 max_allowed_packet
+func (s *MySQLClient) maxAllowedPacket(ctx context.Context, runner sqlRunner) int64 {
+	if v := s.maxPacket.Load(); v > 0 {
+		return v
+	}
+
+	var v int64 = defaultMaxAllowedPacket
+
+	if s.conf.dialect().Name() != "mysql" {
+		return v
+	}
+
+	rows, err := runner.QueryContext(ctx, "SELECT @@max_allowed_packet")
+
+	if err == nil {
+		if rows.Next() {
+			_ = rows.Scan(&v)
+		}
+
+		_ = rows.Close()
+	} else {
+		Log.Warnf("MySQL-MaxAllowedPacket-Error: DataSource=%s, Error=%s", s.conf.Name, err.Error())
+	}
+
+	s.maxPacket.Store(v)
+
+	return v
+}
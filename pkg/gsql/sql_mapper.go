@@ -0,0 +1,269 @@
+package gsql
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/fsnotify/fsnotify"
+
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/utils"
+)
+
+// loadMappers 解析数据源的 mapper 模板, 并用空参数逐个试渲染:
+// 语法错误, 未定义的函数在解析阶段报错; 试渲染失败时 MapperStrict 下返回错误, 否则只记录告警
+func loadMappers(conf *MySQLConf) (*template.Template, error) {
+	var location = rootPath(conf.Location)
+
+	tpl, err := template.New("").Funcs(funcMaps(conf.dialect())).ParseGlob(location)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if sensitive := conf.sensitiveNames(); len(sensitive) > 0 {
+		for _, t := range tpl.Templates() {
+			if t.Tree != nil {
+				markSecretBinds(t.Tree.Root, sensitive)
+			}
+		}
+	}
+
+	names := MapperNames(tpl)
+
+	var failed []error
+
+	for _, name := range names {
+		if err := dryRun(tpl, name, conf.dialect()); err != nil {
+			Log.Warnf("MySQL-Mapper-DryRun-Failed: DataSource=%s, tplName=%s, Error=%s", conf.Name, name, err.Error())
+
+			failed = append(failed, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	if len(failed) > 0 && conf.MapperStrict {
+		return nil, fmt.Errorf("gsql: %d mapper(s) failed dry-run (MYSQL_MAPPER_STRICT): %w", len(failed), errors.Join(failed...))
+	}
+
+	Log.Infof("MySQL-Mappers: DataSource=%s, Location=%s, Count=%d, Templates=%s", conf.Name, conf.Location, len(names), strings.Join(names, ","))
+
+	return tpl, nil
+}
+
+// secretFuncs 参数在 MYSQL_SENSITIVE_PARAMS 中时改写为对应的脱敏函数
+var secretFuncs = map[string]string{
+	"sql_bind":    "sql_bind_secret",
+	"sql_bind_in": "sql_bind_in_secret",
+	"sql_like":    "sql_like_secret",
+}
+
+// markSecretBinds 遍历模板语法树, 把 {{ sql_bind .FVipPhone .CTX }} 这类参数为敏感字段的调用改写为 sql_bind_secret,
+// 渲染时记录绑定值的位置, 日志和 QueryHook 中按位置脱敏
+func markSecretBinds(node parse.Node, sensitive map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+
+		for _, c := range n.Nodes {
+			markSecretBinds(c, sensitive)
+		}
+	case *parse.ActionNode:
+		markSecretBinds(n.Pipe, sensitive)
+	case *parse.IfNode:
+		markSecretBinds(&n.BranchNode, sensitive)
+	case *parse.RangeNode:
+		markSecretBinds(&n.BranchNode, sensitive)
+	case *parse.WithNode:
+		markSecretBinds(&n.BranchNode, sensitive)
+	case *parse.BranchNode:
+		markSecretBinds(n.Pipe, sensitive)
+		markSecretBinds(n.List, sensitive)
+		markSecretBinds(n.ElseList, sensitive)
+	case *parse.TemplateNode:
+		markSecretBinds(n.Pipe, sensitive)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+
+		for _, cmd := range n.Cmds {
+			markSecretBinds(cmd, sensitive)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) >= 2 {
+			if fn, ok := n.Args[0].(*parse.IdentifierNode); ok {
+				if secret, ok := secretFuncs[fn.Ident]; ok && sensitive[normalizeName(argName(n.Args[1]))] {
+					fn.Ident = secret
+				}
+			}
+		}
+
+		for _, arg := range n.Args {
+			markSecretBinds(arg, sensitive)
+		}
+	}
+}
+
+// argName .User.FVipPhone / $u.FVipPhone 取最后一段字段名
+func argName(node parse.Node) string {
+	var idents []string
+
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		idents = n.Ident
+	case *parse.ChainNode:
+		idents = n.Field
+	case *parse.VariableNode:
+		idents = n.Ident
+	}
+
+	if len(idents) == 0 {
+		return ""
+	}
+
+	return idents[len(idents)-1]
+}
+
+// LoadMappers 只加载 conf 的 mapper 模板, 不连接数据库; 返回的 MySQLClient 只能用于 RenderSQL / Mappers,
+// 供 gsqltest 等不需要数据库的场景使用
+func LoadMappers(conf MySQLConf) (*MySQLClient, error) {
+	if _, err := DialectOf(conf.Driver); err != nil {
+		return nil, err
+	}
+
+	tpl, err := loadMappers(&conf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &MySQLClient{render: tpl, conf: &conf}, nil
+}
+
+// rootPath 相对路径基于 utils.RootDir() (WORK_DIR 或当前目录)
+func rootPath(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+
+	return filepath.Join(utils.RootDir(), p)
+}
+
+// dryRun 辅助函数因空参数报出的 BindError 不算模板错误; 渲染成功时还检查 where / set / trim 块是否闭合
+func dryRun(tpl *template.Template, name string, d Dialect) error {
+	var buf bytes.Buffer
+
+	if err := tpl.ExecuteTemplate(&buf, name, map[string]any{"CTX": NewDialectBindContext(d)}); err != nil {
+		if isBindError(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	_, err := expandBlocks(buf.String())
+
+	return err
+}
+
+// MapperNames 返回已加载的模板名称 (即 mapper 文件名), 按字母排序
+func MapperNames(tpl *template.Template) []string {
+	var names []string
+
+	for _, t := range tpl.Templates() {
+		if t.Name() != "" {
+			names = append(names, t.Name())
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Mappers 当前数据源已加载的模板名称
+func (s *MySQLClient) Mappers() []string {
+	return MapperNames(s.template())
+}
+
+// watchMappers 监听 mapper 所在目录, 模板文件变更后重新解析, 解析成功才替换, 失败时保留旧模板
+func (s *MySQLClient) watchMappers() error {
+	var location = rootPath(s.conf.Location)
+
+	watcher, err := fsnotify.NewWatcher()
+
+	if err != nil {
+		return err
+	}
+
+	if err := watcher.Add(filepath.Dir(location)); err != nil {
+		_ = watcher.Close()
+
+		return err
+	}
+
+	s.watcher = watcher
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if matched, _ := filepath.Match(location, event.Name); !matched {
+					continue
+				}
+
+				if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+					continue
+				}
+
+				tpl, err := loadMappers(s.conf)
+
+				if err != nil {
+					Log.Errorf("MySQL-Mapper-Reload-Failed: DataSource=%s, File=%s, Error=%s", s.conf.Name, event.Name, err.Error())
+
+					continue
+				}
+
+				s.setTemplate(tpl)
+
+				Log.Infof("MySQL-Mapper-Reloaded: DataSource=%s, File=%s, Op=%s", s.conf.Name, event.Name, event.Op)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				Log.Errorf("MySQL-Mapper-Watch-Error: DataSource=%s, Error=%s", s.conf.Name, err.Error())
+			}
+		}
+	}()
+
+	Log.Infof("MySQL-Mapper-Watching: DataSource=%s, Location=%s", s.conf.Name, s.conf.Location)
+
+	return nil
+}
+
+func (s *MySQLClient) template() *template.Template {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.render
+}
+
+func (s *MySQLClient) setTemplate(tpl *template.Template) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.render = tpl
+}
@@ -0,0 +1,84 @@
+package gsql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chunhui2001/zero4go/pkg/metrics"
+)
+
+var (
+	queryDuration = metrics.NewHistogram("gsql_query_duration_seconds", "SQL 执行耗时", nil, "data_source", "template", "action")
+	queryErrors   = metrics.NewCounter("gsql_query_errors_total", "SQL 执行失败数 (不含查询无结果)", "data_source", "template", "action", "kind")
+)
+
+// metricsHook 按数据源、模板和操作记录 SQL 耗时和错误数
+type metricsHook struct{}
+
+func (metricsHook) Before(ctx context.Context, _ *QueryEvent) context.Context {
+	return ctx
+}
+
+func (metricsHook) After(_ context.Context, e *QueryEvent) {
+	queryDuration.Observe(e.Duration.Seconds(), e.DataSource, e.TplName, e.Action)
+
+	if e.Err != nil && !errors.Is(e.Err, ErrNoRows) {
+		queryErrors.Inc(e.DataSource, e.TplName, e.Action, errorKind(e.Err))
+	}
+}
+
+// errorKind 错误分类, 标签取值有限
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrDuplicateKey):
+		return "duplicate_key"
+	case errors.Is(err, ErrDeadlock):
+		return "deadlock"
+	case errors.Is(err, ErrLockWaitTimeout):
+		return "lock_wait_timeout"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+
+	return "other"
+}
+
+func poolSamples(value func(p PoolStats) float64) []metrics.Sample {
+	var samples []metrics.Sample
+
+	for _, p := range Stats() {
+		samples = append(samples, metrics.Sample{LabelValues: []string{p.DataSource, p.Server, p.Role}, Value: value(p)})
+	}
+
+	return samples
+}
+
+func init() {
+	AddQueryHook(metricsHook{})
+
+	labels := []string{"data_source", "server", "role"}
+
+	metrics.NewGaugeFunc("gsql_pool_open_connections", "连接池中已建立的连接数", labels, func() []metrics.Sample {
+		return poolSamples(func(p PoolStats) float64 { return float64(p.OpenConns) })
+	})
+	metrics.NewGaugeFunc("gsql_pool_in_use_connections", "连接池中使用中的连接数", labels, func() []metrics.Sample {
+		return poolSamples(func(p PoolStats) float64 { return float64(p.InUse) })
+	})
+	metrics.NewGaugeFunc("gsql_pool_idle_connections", "连接池中空闲的连接数", labels, func() []metrics.Sample {
+		return poolSamples(func(p PoolStats) float64 { return float64(p.Idle) })
+	})
+	metrics.NewCounterFunc("gsql_pool_wait_total", "等待空闲连接的累计次数", labels, func() []metrics.Sample {
+		return poolSamples(func(p PoolStats) float64 { return float64(p.WaitCount) })
+	})
+	metrics.NewGaugeFunc("gsql_pool_healthy", "连接池是否可用 (1 可用), 从库由健康检查更新", labels, func() []metrics.Sample {
+		return poolSamples(func(p PoolStats) float64 {
+			if p.Healthy {
+				return 1
+			}
+
+			return 0
+		})
+	})
+}
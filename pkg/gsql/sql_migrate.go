@@ -0,0 +1,585 @@
+package gsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+)
+
+const migrationTable = "gsql_schema_migrations"
+
+// 迁移文件命名: <version>_<name>.up.sql / <version>_<name>.down.sql, 如 20250101120000_create_orders.up.sql
+var reMigrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+const migrationLockTable = "gsql_schema_migrations_lock"
+
+// PostgreSQL 的 $$ / $body$ 引用
+var reDollarTag = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+type Migration struct {
+	Version   int64
+	Name      string
+	UpFile    string
+	DownFile  string
+	AppliedAt *time.Time
+}
+
+// migrations 读取 MYSQL_MIGRATION_LOCATION 目录下的迁移文件, 按版本号升序
+func (s *MySQLClient) migrations() ([]*Migration, error) {
+	var dir = rootPath(s.conf.MigrationLocation)
+
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, e := range entries {
+		m := reMigrationFile.FindStringSubmatch(e.Name())
+
+		if e.IsDir() || m == nil {
+			continue
+		}
+
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+
+		if byVersion[version] == nil {
+			byVersion[version] = &Migration{Version: version, Name: m[2]}
+		}
+
+		if m[3] == "up" {
+			byVersion[version].UpFile = filepath.Join(dir, e.Name())
+		} else {
+			byVersion[version].DownFile = filepath.Join(dir, e.Name())
+		}
+	}
+
+	var out []*Migration
+
+	for _, m := range byVersion {
+		out = append(out, m)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+
+	return out, nil
+}
+
+// MigrationStatus 所有迁移及其执行时间, 未执行的 AppliedAt 为 nil
+func (s *MySQLClient) MigrationStatus(ctx context.Context) ([]*Migration, error) {
+	conn, err := s.Conn(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	if err := ensureMigrationTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	return s.migrationStatus(ctx, conn)
+}
+
+func (s *MySQLClient) migrationStatus(ctx context.Context, conn *sql.Conn) ([]*Migration, error) {
+	all, err := s.migrations()
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM "+migrationTable)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+
+		if err := rows.Scan(&version, &timeScanner{dest: reflect.ValueOf(&appliedAt).Elem()}); err != nil {
+			return nil, err
+		}
+
+		applied[version] = appliedAt
+	}
+
+	for _, m := range all {
+		if t, ok := applied[m.Version]; ok {
+			m.AppliedAt = &t
+		}
+	}
+
+	return all, rows.Err()
+}
+
+// MigrateUp 按版本号升序执行未执行的迁移, steps <= 0 表示全部执行, 返回执行的个数
+func (s *MySQLClient) MigrateUp(ctx context.Context, steps int) (int, error) {
+	return s.migrate(ctx, true, steps)
+}
+
+// MigrateDown 按版本号降序回滚已执行的迁移, steps <= 0 时回滚 1 个, 返回回滚的个数
+func (s *MySQLClient) MigrateDown(ctx context.Context, steps int) (int, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+
+	return s.migrate(ctx, false, steps)
+}
+
+func (s *MySQLClient) migrate(ctx context.Context, up bool, steps int) (int, error) {
+	if s.conf.MigrationLocation == "" {
+		return 0, fmt.Errorf("gsql: MYSQL_MIGRATION_LOCATION is not configured for datasource %s", s.conf.Name)
+	}
+
+	// 锁和迁移使用同一个连接, GET_LOCK 是连接级别的
+	conn, err := s.Conn(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer conn.Close()
+
+	if err := s.lockMigration(ctx, conn); err != nil {
+		return 0, err
+	}
+
+	defer s.unlockMigration(conn)
+
+	if err := ensureMigrationTable(ctx, conn); err != nil {
+		return 0, err
+	}
+
+	all, err := s.migrationStatus(ctx, conn)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var pending []*Migration
+
+	if up {
+		for _, m := range all {
+			if m.AppliedAt == nil {
+				pending = append(pending, m)
+			}
+		}
+	} else {
+		for i := len(all) - 1; i >= 0; i-- {
+			if all[i].AppliedAt != nil {
+				pending = append(pending, all[i])
+			}
+		}
+	}
+
+	if steps > 0 && len(pending) > steps {
+		pending = pending[:steps]
+	}
+
+	for i, m := range pending {
+		if err := s.applyMigration(ctx, conn, m, up); err != nil {
+			return i, err
+		}
+	}
+
+	return len(pending), nil
+}
+
+func (s *MySQLClient) applyMigration(ctx context.Context, conn *sql.Conn, m *Migration, up bool) error {
+	var file, direction = m.UpFile, "up"
+
+	if !up {
+		file, direction = m.DownFile, "down"
+	}
+
+	if file == "" {
+		return fmt.Errorf("gsql: migration %d_%s has no %s file", m.Version, m.Name, direction)
+	}
+
+	content, err := os.ReadFile(file)
+
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	var d = s.conf.dialect()
+	var runner execer = conn
+	var tx *sql.Tx
+
+	// PostgreSQL / SQLite 的 DDL 可以回滚, 每个迁移文件和版本记录在同一个事务中执行;
+	// MySQL 的 DDL 会隐式提交, 失败时已执行的语句不会回滚, 需修复后手动处理
+	if transactionalDDL(d) {
+		if tx, err = conn.BeginTx(ctx, nil); err != nil {
+			return err
+		}
+
+		defer func() {
+			_ = tx.Rollback()
+		}()
+
+		runner = tx
+	}
+
+	for _, stmt := range splitStatements(string(content), d.Name() == "mysql") {
+		if _, err := runner.ExecContext(ctx, stmt); err != nil {
+			Log.Errorf("MySQL-Migrate-Failed: DataSource=%s, Version=%d, Name=%s, Direction=%s, Error=%s", s.conf.Name, m.Version, m.Name, direction, err.Error())
+
+			return fmt.Errorf("gsql: migration %d_%s %s: %w", m.Version, m.Name, direction, err)
+		}
+	}
+
+	if up {
+		_, err = runner.ExecContext(ctx, rebind(d, "INSERT INTO "+migrationTable+" (version, name) VALUES (?, ?)"), m.Version, m.Name)
+	} else {
+		_, err = runner.ExecContext(ctx, rebind(d, "DELETE FROM "+migrationTable+" WHERE version = ?"), m.Version)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	Log.Infof("MySQL-Migrate-Succeed: DataSource=%s, Version=%d, Name=%s, Direction=%s, Duration=%s", s.conf.Name, m.Version, m.Name, direction, time.Since(start))
+
+	return nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// transactionalDDL DDL 是否可以在事务中执行并回滚
+func transactionalDDL(d Dialect) bool {
+	switch d.Name() {
+	case "postgres", "sqlite":
+		return true
+	}
+
+	return false
+}
+
+func ensureMigrationTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+migrationTable+` (
+    version    BIGINT       NOT NULL PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`)
+
+	return err
+}
+
+// lockMigration 多个节点同时启动时只有一个节点执行迁移, 其余节点最多等待 MYSQL_MIGRATION_LOCK_TIMEOUT:
+// MySQL 使用 GET_LOCK, PostgreSQL 使用 pg_try_advisory_lock, 均为连接级别, 连接断开时自动释放;
+// 其他方言 (如 SQLite) 在 gsql_schema_migrations_lock 表中插入一行, 进程异常退出时需手动删除该行
+func (s *MySQLClient) lockMigration(ctx context.Context, conn *sql.Conn) error {
+	var timeout = s.conf.MigrationLockTimeout
+
+	switch s.conf.dialect().Name() {
+	case "mysql":
+		var got sql.NullInt64
+
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", s.migrationLockName(), int(timeout.Seconds())).Scan(&got); err != nil {
+			return err
+		}
+
+		if !got.Valid || got.Int64 != 1 {
+			return fmt.Errorf("gsql: failed to acquire migration lock for datasource %s within %s", s.conf.Name, timeout)
+		}
+
+		return nil
+	case "postgres":
+		return s.pollMigrationLock(ctx, func() (bool, error) {
+			var got bool
+
+			err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", s.migrationLockName()).Scan(&got)
+
+			return got, err
+		})
+	}
+
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+migrationLockTable+` (
+    name      VARCHAR(255) NOT NULL PRIMARY KEY,
+    locked_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`)
+
+	if err != nil {
+		return err
+	}
+
+	return s.pollMigrationLock(ctx, func() (bool, error) {
+		// 主键冲突即其他节点持有锁
+		_, err := conn.ExecContext(ctx, rebind(s.conf.dialect(), "INSERT INTO "+migrationLockTable+" (name) VALUES (?)"), s.migrationLockName())
+
+		return err == nil, nil
+	})
+}
+
+// pollMigrationLock 每秒尝试一次, 直到获得锁或超时
+func (s *MySQLClient) pollMigrationLock(ctx context.Context, try func() (bool, error)) error {
+	var deadline = time.Now().Add(s.conf.MigrationLockTimeout)
+
+	for {
+		got, err := try()
+
+		if err != nil {
+			return err
+		}
+
+		if got {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("gsql: failed to acquire migration lock for datasource %s within %s", s.conf.Name, s.conf.MigrationLockTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (s *MySQLClient) unlockMigration(conn *sql.Conn) {
+	var err error
+
+	switch s.conf.dialect().Name() {
+	case "mysql":
+		_, err = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", s.migrationLockName())
+	case "postgres":
+		_, err = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", s.migrationLockName())
+	default:
+		_, err = conn.ExecContext(context.Background(), rebind(s.conf.dialect(), "DELETE FROM "+migrationLockTable+" WHERE name = ?"), s.migrationLockName())
+	}
+
+	if err != nil {
+		Log.Errorf("MySQL-Migrate-Unlock-Failed: DataSource=%s, Error=%s", s.conf.Name, err.Error())
+	}
+}
+
+func (s *MySQLClient) migrationLockName() string {
+	return "gsql_migrate_" + s.conf.Name
+}
+
+// splitStatements 按分隔符 (默认 ;) 切分语句, 引号、注释和 PostgreSQL 的 $$ 块中的分隔符不切分;
+// 单独一行的 DELIMITER // 修改之后的分隔符 (与 mysql 客户端相同), 用于存储过程、触发器等包含 ; 的语句:
+//
+//	DELIMITER //
+//	CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END //
+//	DELIMITER ;
+//
+// backslash 为 true 时 (MySQL) 字符串中的 \ 转义下一个字符
+func splitStatements(content string, backslash bool) []string {
+	var (
+		out       []string
+		sb        strings.Builder
+		delimiter = ";"
+		hasCode   = false // 只有注释的语句不执行
+		lineStart = true
+	)
+
+	flush := func() {
+		if stmt := strings.TrimSpace(sb.String()); stmt != "" && hasCode {
+			out = append(out, stmt)
+		}
+
+		sb.Reset()
+		hasCode = false
+	}
+
+	for i := 0; i < len(content); {
+		if lineStart {
+			lineStart = false
+
+			line, _, _ := strings.Cut(content[i:], "\n")
+
+			if f := strings.Fields(line); len(f) == 2 && strings.EqualFold(f[0], "DELIMITER") {
+				flush()
+
+				delimiter = f[1]
+				i += len(line)
+				continue
+			}
+		}
+
+		rest := content[i:]
+		n := 1
+
+		switch {
+		case rest[0] == '\'' || rest[0] == '"' || rest[0] == '`':
+			n = quotedLen(rest, backslash)
+		case strings.HasPrefix(rest, "--"):
+			n = strings.IndexByte(rest, '\n')
+		case strings.HasPrefix(rest, "/*"):
+			if end := strings.Index(rest[2:], "*/"); end >= 0 {
+				n = end + 4
+			} else {
+				n = -1
+			}
+		case strings.HasPrefix(rest, delimiter):
+			flush()
+
+			i += len(delimiter)
+			continue
+		case rest[0] == '$':
+			if tag := reDollarTag.FindString(rest); tag != "" {
+				if end := strings.Index(rest[len(tag):], tag); end >= 0 {
+					n = len(tag) + end + len(tag)
+				} else {
+					n = -1
+				}
+			}
+		}
+
+		if n < 0 {
+			n = len(rest)
+		}
+
+		if !strings.HasPrefix(rest, "--") && !strings.HasPrefix(rest, "/*") && strings.TrimSpace(rest[:n]) != "" {
+			hasCode = true
+		}
+
+		if rest[n-1] == '\n' {
+			lineStart = true
+		}
+
+		sb.WriteString(rest[:n])
+		i += n
+	}
+
+	flush()
+
+	return out
+}
+
+// quotedLen s 以引号开头, 返回到匹配的引号为止的长度; 字符串中连续两个引号 (转义的引号) 视为两个相邻的字符串, 结果相同
+func quotedLen(s string, backslash bool) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if backslash && s[0] != '`' {
+				i++
+			}
+		case s[0]:
+			return i + 1
+		}
+	}
+
+	return len(s)
+}
+
+func (s *MySQLClient) migrateOnStartup() {
+	if !s.conf.MigrateOnStartup || s.conf.MigrationLocation == "" {
+		return
+	}
+
+	n, err := s.MigrateUp(context.Background(), 0)
+
+	if err != nil {
+		panic(err)
+	}
+
+	Log.Infof("MySQL-Migrate-Up: DataSource=%s, Applied=%d", s.conf.Name, n)
+}
+
+// RunMigrations 对 dataSource 执行迁移, dataSource 为空时对所有配置了 MYSQL_MIGRATION_LOCATION 的数据源执行;
+// action 为 up / down / status
+func RunMigrations(ctx context.Context, action string, dataSource string, steps int) error {
+	var clients []*MySQLClient
+
+	if Client.DB != nil {
+		clients = append(clients, &Client)
+	}
+
+	var names []string
+
+	for name := range DataSouces {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		clients = append(clients, DataSouces[name])
+	}
+
+	var matched = 0
+
+	for _, c := range clients {
+		if (dataSource != "" && c.conf.Name != dataSource) || (dataSource == "" && c.conf.MigrationLocation == "") {
+			continue
+		}
+
+		matched++
+
+		switch action {
+		case "up":
+			n, err := c.MigrateUp(ctx, steps)
+
+			if err != nil {
+				return err
+			}
+
+			Log.Infof("MySQL-Migrate-Up: DataSource=%s, Applied=%d", c.conf.Name, n)
+		case "down":
+			n, err := c.MigrateDown(ctx, steps)
+
+			if err != nil {
+				return err
+			}
+
+			Log.Infof("MySQL-Migrate-Down: DataSource=%s, RolledBack=%d", c.conf.Name, n)
+		case "status":
+			all, err := c.MigrationStatus(ctx)
+
+			if err != nil {
+				return err
+			}
+
+			for _, m := range all {
+				var appliedAt = "pending"
+
+				if m.AppliedAt != nil {
+					appliedAt = m.AppliedAt.Format(time.DateTime)
+				}
+
+				Log.Infof("MySQL-Migrate-Status: DataSource=%s, Version=%d, Name=%s, AppliedAt=%s", c.conf.Name, m.Version, m.Name, appliedAt)
+			}
+		default:
+			return fmt.Errorf("gsql: unknown migrate action %q", action)
+		}
+	}
+
+	if matched == 0 {
+		return fmt.Errorf("gsql: no datasource to migrate: name=%q", dataSource)
+	}
+
+	return nil
+}
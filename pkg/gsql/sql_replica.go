@@ -0,0 +1,144 @@
+package gsql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+)
+
+type primaryKey struct{}
+
+// WithPrimary 强制本次调用的查询走主库, 用于写后立即读的场景
+//
+//	gsql.SelectRowContext[Order](gsql.WithPrimary(ctx), "zero4rs_db", "order_select.txt", params)
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryKey{}).(bool)
+
+	return v
+}
+
+type replica struct {
+	*sql.DB
+	server  string
+	healthy atomic.Bool
+	latency atomic.Int64 // 最近一次 ping 耗时, 纳秒
+}
+
+// setupReplicas 连接配置的从库, 并启动健康检查
+func (s *MySQLClient) setupReplicas() {
+	if strings.TrimSpace(s.conf.Replicas) == "" {
+		return
+	}
+
+	for _, server := range strings.Split(s.conf.Replicas, ",") {
+		server = strings.TrimSpace(server)
+
+		if server == "" {
+			continue
+		}
+
+		db, err := s.conf.open(server)
+
+		if err != nil {
+			Log.Errorf("MySQL-Replica-Failed: Name=%s, Error=%s, ConnectionString=%s", s.conf.Name, err.Error(), s.conf.connStringOf(server, "****"))
+
+			continue
+		}
+
+		r := &replica{DB: db, server: server}
+
+		r.ping(s.conf)
+
+		s.replicas = append(s.replicas, r)
+
+		Log.Infof("MySQL-Replica-Added: Name=%s, Server=%s, Healthy=%t", s.conf.Name, server, r.healthy.Load())
+	}
+
+	if len(s.replicas) > 0 && s.conf.ReplicaCheckInterval > 0 {
+		s.stop = make(chan struct{})
+
+		go s.checkReplicas()
+	}
+}
+
+func (s *MySQLClient) checkReplicas() {
+	ticker := time.NewTicker(s.conf.ReplicaCheckInterval)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			for _, r := range s.replicas {
+				r.ping(s.conf)
+			}
+		}
+	}
+}
+
+func (r *replica) ping(conf *MySQLConf) {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*3)
+
+	defer cancelFunc()
+
+	start := time.Now()
+	err := r.PingContext(ctx)
+
+	r.latency.Store(int64(time.Since(start)))
+
+	if err != nil {
+		if r.healthy.Swap(false) {
+			Log.Warnf("MySQL-Replica-Ejected: Name=%s, Server=%s, Error=%s", conf.Name, r.server, err.Error())
+		}
+
+		return
+	}
+
+	if !r.healthy.Swap(true) {
+		Log.Infof("MySQL-Replica-Recovered: Name=%s, Server=%s, Latency=%s", conf.Name, r.server, time.Duration(r.latency.Load()))
+	}
+}
+
+// reader 返回查询使用的连接池: 未配置从库, 从库全部不可用, 或 ctx 指定 WithPrimary 时使用主库
+func (s *MySQLClient) reader(ctx context.Context) *sql.DB {
+	if len(s.replicas) == 0 || usePrimary(ctx) {
+		return s.DB
+	}
+
+	var picked *replica
+
+	if s.conf.ReplicaPolicy == "least_latency" {
+		for _, r := range s.replicas {
+			if r.healthy.Load() && (picked == nil || r.latency.Load() < picked.latency.Load()) {
+				picked = r
+			}
+		}
+	} else {
+		n := uint64(len(s.replicas))
+		start := s.next.Add(1)
+
+		for i := uint64(0); i < n; i++ {
+			if r := s.replicas[(start+i)%n]; r.healthy.Load() {
+				picked = r
+
+				break
+			}
+		}
+	}
+
+	if picked == nil {
+		return s.DB
+	}
+
+	return picked.DB
+}
@@ -0,0 +1,75 @@
+package gsql
+
+import (
+	"database/sql"
+	"sort"
+)
+
+// PoolStats 连接池状态, 由 sql.DBStats 转换而来, 用于调整连接池大小
+type PoolStats struct {
+	DataSource        string `json:"data_source"`
+	Server            string `json:"server"`
+	Role              string `json:"role"` // primary / replica
+	Healthy           bool   `json:"healthy"`
+	MaxOpenConns      int    `json:"max_open_conns"`
+	OpenConns         int    `json:"open_conns"`
+	InUse             int    `json:"in_use"`
+	Idle              int    `json:"idle"`
+	WaitCount         int64  `json:"wait_count"`
+	WaitDurationMs    int64  `json:"wait_duration_ms"`
+	MaxIdleClosed     int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed int64  `json:"max_lifetime_closed"`
+}
+
+func newPoolStats(name string, server string, role string, healthy bool, s sql.DBStats) PoolStats {
+	return PoolStats{
+		DataSource:        name,
+		Server:            server,
+		Role:              role,
+		Healthy:           healthy,
+		MaxOpenConns:      s.MaxOpenConnections,
+		OpenConns:         s.OpenConnections,
+		InUse:             s.InUse,
+		Idle:              s.Idle,
+		WaitCount:         s.WaitCount,
+		WaitDurationMs:    s.WaitDuration.Milliseconds(),
+		MaxIdleClosed:     s.MaxIdleClosed,
+		MaxIdleTimeClosed: s.MaxIdleTimeClosed,
+		MaxLifetimeClosed: s.MaxLifetimeClosed,
+	}
+}
+
+// PoolStats 当前数据源主库及各从库的连接池状态
+func (s *MySQLClient) PoolStats() []PoolStats {
+	var out = []PoolStats{newPoolStats(s.conf.Name, s.conf.Server, "primary", true, s.Stats())}
+
+	for _, r := range s.replicas {
+		out = append(out, newPoolStats(s.conf.Name, r.server, "replica", r.healthy.Load(), r.Stats()))
+	}
+
+	return out
+}
+
+// Stats 所有已初始化数据源的连接池状态
+func Stats() []PoolStats {
+	var out []PoolStats
+
+	if Client.DB != nil {
+		out = append(out, Client.PoolStats()...)
+	}
+
+	var names []string
+
+	for name := range DataSouces {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		out = append(out, DataSouces[name].PoolStats()...)
+	}
+
+	return out
+}
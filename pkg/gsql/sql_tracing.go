@@ -0,0 +1,49 @@
+package gsql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chunhui2001/zero4go/pkg/tracing"
+)
+
+type spanKey struct{}
+
+// tracingHook 为每次 SQL 执行创建 client Span, db.statement 为不含绑定值的 SQL
+type tracingHook struct{}
+
+func (tracingHook) Before(ctx context.Context, e *QueryEvent) context.Context {
+	ctx, span := tracing.Start(ctx, "gsql "+e.Action+" "+e.TplName, tracing.KindClient)
+
+	if span == nil {
+		return ctx
+	}
+
+	span.SetAttr("db.system", e.DBSystem)
+	span.SetAttr("db.name", e.DataSource)
+	span.SetAttr("db.operation", e.Action)
+	span.SetAttr("db.statement", e.SQL)
+	span.SetAttr("gsql.template", e.TplName)
+
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func (tracingHook) After(ctx context.Context, e *QueryEvent) {
+	span, _ := ctx.Value(spanKey{}).(*tracing.Span)
+
+	if span == nil {
+		return
+	}
+
+	span.SetAttr("db.rows", e.Rows)
+
+	if !errors.Is(e.Err, ErrNoRows) {
+		span.SetError(e.Err)
+	}
+
+	span.End()
+}
+
+func init() {
+	AddQueryHook(tracingHook{})
+}
@@ -0,0 +1,195 @@
+package gsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+)
+
+// Tx 事务句柄, 提供与 MySQLClient 相同的模板化 Insert/Update/Delete,
+// 查询使用 TxSelectRow / TxSelectRows
+type Tx struct {
+	tx     *sql.Tx
+	ctx    context.Context
+	client *MySQLClient
+	depth  int             // 嵌套层级, 0 为最外层事务
+	tables map[string]bool // 事务中写过的表, 提交后清除其查询缓存; 嵌套事务共用
+}
+
+type TxOption func(*sql.TxOptions)
+
+// WithIsolation 设置事务隔离级别, 默认使用数据库的隔离级别
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(o *sql.TxOptions) {
+		o.Isolation = level
+	}
+}
+
+// WithReadOnly 开启只读事务
+func WithReadOnly() TxOption {
+	return func(o *sql.TxOptions) {
+		o.ReadOnly = true
+	}
+}
+
+// Tx 在一个事务中执行 fn: fn 返回 nil 时提交, 返回 error 或 panic 时回滚
+//
+//	err := gsql.Client.Tx(ctx, func(tx *gsql.Tx) error {
+//		id, err := tx.Insert("order_insert.txt", order)
+//		...
+//		_, err = tx.Update("order_update.txt", map[string]any{"ID": id, ...})
+//		return err
+//	})
+//
+// 死锁或锁等待超时时整个事务会重新执行 (见 MYSQL_DEADLOCK_RETRIES), fn 除数据库操作外不应有其他副作用
+func (s *MySQLClient) Tx(ctx context.Context, fn func(tx *Tx) error, opts ...TxOption) error {
+	_, err := withRetry(ctx, s, "Tx", "", func() (struct{}, error) {
+		return struct{}{}, s.tx(ctx, fn, opts)
+	})
+
+	return err
+}
+
+func (s *MySQLClient) tx(ctx context.Context, fn func(tx *Tx) error, opts []TxOption) (err error) {
+	var txOptions sql.TxOptions
+
+	for _, opt := range opts {
+		opt(&txOptions)
+	}
+
+	sqlTx, err := s.BeginTx(ctx, &txOptions)
+
+	if err != nil {
+		Log.Errorf("MySQL-Tx-Begin-Error: DataSource=%s, Error=%s", s.conf.Name, err.Error())
+
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			if rbErr := sqlTx.Rollback(); rbErr != nil {
+				Log.Errorf("MySQL-Tx-Rollback-Error: DataSource=%s, Error=%s", s.conf.Name, rbErr.Error())
+			}
+
+			panic(p)
+		}
+	}()
+
+	tx := &Tx{tx: sqlTx, ctx: ctx, client: s, tables: make(map[string]bool)}
+
+	if err = fn(tx); err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil {
+			Log.Errorf("MySQL-Tx-Rollback-Error: DataSource=%s, Error=%s", s.conf.Name, rbErr.Error())
+		}
+
+		return err
+	}
+
+	if err = sqlTx.Commit(); err != nil {
+		Log.Errorf("MySQL-Tx-Commit-Error: DataSource=%s, Error=%s", s.conf.Name, err.Error())
+
+		return s.wrapError("Tx", "", err)
+	}
+
+	tx.invalidateCache()
+
+	return nil
+}
+
+// Tx 嵌套事务, 基于 SAVEPOINT 实现: fn 失败时只回滚到该保存点, 外层事务不受影响
+func (t *Tx) Tx(fn func(tx *Tx) error) (err error) {
+	nested := &Tx{tx: t.tx, ctx: t.ctx, client: t.client, depth: t.depth + 1, tables: t.tables}
+	savepoint := fmt.Sprintf("sp_%d", nested.depth)
+
+	if _, err = t.tx.ExecContext(t.ctx, "SAVEPOINT "+savepoint); err != nil {
+		Log.Errorf("MySQL-Tx-Savepoint-Error: DataSource=%s, Savepoint=%s, Error=%s", t.client.conf.Name, savepoint, err.Error())
+
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			nested.rollbackTo(savepoint)
+
+			panic(p)
+		}
+	}()
+
+	if err = fn(nested); err != nil {
+		nested.rollbackTo(savepoint)
+
+		return err
+	}
+
+	if _, err = t.tx.ExecContext(t.ctx, "RELEASE SAVEPOINT "+savepoint); err != nil {
+		Log.Errorf("MySQL-Tx-Savepoint-Error: DataSource=%s, Savepoint=%s, Error=%s", t.client.conf.Name, savepoint, err.Error())
+	}
+
+	return err
+}
+
+func (t *Tx) rollbackTo(savepoint string) {
+	if _, err := t.tx.ExecContext(t.ctx, "ROLLBACK TO SAVEPOINT "+savepoint); err != nil {
+		Log.Errorf("MySQL-Tx-Rollback-Error: DataSource=%s, Savepoint=%s, Error=%s", t.client.conf.Name, savepoint, err.Error())
+	}
+}
+
+// SqlTx 返回底层的 *sql.Tx
+func (t *Tx) SqlTx() *sql.Tx {
+	return t.tx
+}
+
+func (t *Tx) Insert(tplName string, params map[string]any) (int64, error) {
+	t.touchTemplate(tplName)
+
+	return t.client.execute(t.ctx, t.tx, "Insert", tplName, params)
+}
+
+func (t *Tx) Update(tplName string, params map[string]any) (int64, error) {
+	t.touchTemplate(tplName)
+
+	return t.client.execute(t.ctx, t.tx, "Update", tplName, params)
+}
+
+func (t *Tx) Delete(tplName string, params map[string]any) (int64, error) {
+	t.touchTemplate(tplName)
+
+	return t.client.execute(t.ctx, t.tx, "Delete", tplName, params)
+}
+
+// touchTemplate 记录写模板标记的表, 事务提交后才清除缓存, 避免提交前其他请求把旧数据重新写入缓存
+func (t *Tx) touchTemplate(tplName string) {
+	if t.client.cache != nil {
+		t.touch(t.client.cache.tables[tplName]...)
+	}
+}
+
+func (t *Tx) touch(tables ...string) {
+	for _, table := range tables {
+		t.tables[table] = true
+	}
+}
+
+func (t *Tx) invalidateCache() {
+	if t.client.cache == nil || len(t.tables) == 0 {
+		return
+	}
+
+	var tables []string
+
+	for table := range t.tables {
+		tables = append(tables, table)
+	}
+
+	t.client.cache.invalidate(t.ctx, tables)
+}
+
+func TxSelectRow[T any](tx *Tx, tplName string, params map[string]any) (*T, error) {
+	return selectRow[T](tx.ctx, tx.tx, tx.client, tplName, params)
+}
+
+func TxSelectRows[T any](tx *Tx, tplName string, params map[string]any) ([]T, error) {
+	return selectRows[T](tx.ctx, tx.tx, tx.client, tplName, params)
+}
@@ -1,19 +1,54 @@
 package gtask
 
 import (
+	"context"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/robfig/cron"
 
 	"github.com/chunhui2001/zero4go/pkg/gredis"
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/single"
 )
 
 var c = cron.New()
 
+// running 正在执行的任务, 关闭时等待其结束
+var running sync.WaitGroup
+
+// stopping 已开始关闭, 之后触发的任务不再执行; 与 running.Add 同在 mu 下, 避免 Add 与 Wait 并发
+var (
+	mu       sync.Mutex
+	stopping bool
+)
+
 func init() {
 	c.Start()
+
+	// 停止调度新的任务, 等待正在执行的任务结束 (不会中断), 超时后返回
+	single.AddHook("cron", single.PriorityConsumer, 0, func(ctx context.Context) error {
+		mu.Lock()
+		stopping = true
+		mu.Unlock()
+
+		c.Stop()
+
+		done := make(chan struct{})
+
+		go func() {
+			running.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
 }
 
 // AddTask 添加一个定时任务
@@ -36,6 +71,12 @@ func init() {
 // ###################################################################################
 func AddTask(name string, JobID string, spec string, tasks func(key string)) {
 	_ = c.AddFunc(spec, func() {
+		if !begin() {
+			return
+		}
+
+		defer running.Done()
+
 		gredis.Lock(JobID, 1*time.Second, 330*time.Millisecond, func() {
 			var _key = JobID + "#" + time.Now().UTC().Format("2006-01-02T15:04:05")
 
@@ -47,3 +88,17 @@ func AddTask(name string, JobID string, spec string, tasks func(key string)) {
 
 	Log.Infof(`注册了一个定时任务: Name=%s, JobID=%s, Expr='%s'`, name, JobID, spec)
 }
+
+// begin 登记一次任务执行, 已开始关闭时返回 false
+func begin() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if stopping {
+		return false
+	}
+
+	running.Add(1)
+
+	return true
+}
@@ -2,6 +2,7 @@ package gzook
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"net"
 	"regexp"
@@ -11,7 +12,9 @@ import (
 	"github.com/bytedance/gopkg/util/logger"
 	"github.com/go-zookeeper/zk"
 
+	"github.com/chunhui2001/zero4go/pkg/health"
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/single"
 )
 
 type ZookConf struct {
@@ -47,6 +50,21 @@ func Init() {
 		conn,
 	}
 
+	health.Register("zookeeper", func(context.Context) error {
+		if state := conn.State(); state != zk.StateHasSession {
+			return fmt.Errorf("zookeeper session state: %s", state)
+		}
+
+		return nil
+	})
+
+	single.AddHook("zookeeper", single.PriorityClient, 0, func(context.Context) error {
+		// 关闭会话, 会话中创建的临时节点 (如 TryLock 的锁) 随之删除
+		conn.Close()
+
+		return nil
+	})
+
 	Log.Infof(`ZooKeeper-Connect-Succeed: ConnectTimeout=%s, Servers=%s, SessionId=%d`, timeOut, Settings.Servers, conn.SessionID())
 
 	Client.Info()
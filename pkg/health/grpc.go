@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCServer 标准 gRPC health 服务 (grpc.health.v1.Health): service 为空时返回整体就绪状态, 否则为同名组件的状态
+type GRPCServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func NewGRPCServer() *GRPCServer {
+	return &GRPCServer{}
+}
+
+func (s *GRPCServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, ok := servingStatus(ctx, req.GetService())
+
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown service: %s", req.GetService())
+	}
+
+	return &healthpb.HealthCheckResponse{Status: st}, nil
+}
+
+func (s *GRPCServer) List(ctx context.Context, _ *healthpb.HealthListRequest) (*healthpb.HealthListResponse, error) {
+	report := Ready(ctx)
+
+	statuses := map[string]*healthpb.HealthCheckResponse{
+		"": {Status: toServing(report.Status)},
+	}
+
+	for name, comp := range report.Components {
+		statuses[name] = &healthpb.HealthCheckResponse{Status: toServing(comp.Status)}
+	}
+
+	return &healthpb.HealthListResponse{Statuses: statuses}, nil
+}
+
+// Watch 每隔 HEALTH_CACHE_TTL 检查一次, 状态变化时推送; 未注册的组件推送 SERVICE_UNKNOWN
+func (s *GRPCServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	interval := Settings.CacheTTL
+
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+
+	for {
+		st, ok := servingStatus(stream.Context(), req.GetService())
+
+		if !ok {
+			st = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+
+		if st != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return status.Error(codes.Canceled, "stream has ended")
+			}
+
+			last = st
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return status.Error(codes.Canceled, "stream has ended")
+		case <-ticker.C:
+		}
+	}
+}
+
+func servingStatus(ctx context.Context, service string) (healthpb.HealthCheckResponse_ServingStatus, bool) {
+	if service == "" {
+		return toServing(Ready(ctx).Status), true
+	}
+
+	comp := Check(ctx, service)
+
+	if comp == nil {
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+	}
+
+	return toServing(comp.Status), true
+}
+
+func toServing(s string) healthpb.HealthCheckResponse_ServingStatus {
+	if s == StatusUp {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
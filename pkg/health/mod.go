@@ -0,0 +1,233 @@
+// Package health 汇总各子系统 (MySQL, Redis, Kafka, ElasticSearch, OpenSearch, ZooKeeper) 的健康状态,
+// 供 /health/live, /health/ready 和 gRPC health 服务使用. 各子系统在 Init 成功后调用 Register 注册检查函数
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/single"
+)
+
+const (
+	StatusUp   = "UP"
+	StatusDown = "DOWN"
+)
+
+type HealthConf struct {
+	// CacheTTL 检查结果的缓存时间, 避免探针和看板频繁访问时压垮依赖
+	CacheTTL time.Duration `mapstructure:"HEALTH_CACHE_TTL"`
+	// Timeout 单个检查的默认超时
+	Timeout time.Duration `mapstructure:"HEALTH_CHECK_TIMEOUT"`
+}
+
+var Settings = &HealthConf{
+	CacheTTL: time.Second * 2,
+	Timeout:  time.Second * 2,
+}
+
+// Component 一个子系统的检查结果
+type Component struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	CheckedAt string  `json:"checked_at"`
+}
+
+// Report 整体状态: 任一 critical 组件 DOWN 或进程正在关闭时为 DOWN
+type Report struct {
+	Status     string                `json:"status"`
+	Draining   bool                  `json:"draining,omitempty"`
+	Components map[string]*Component `json:"components"`
+}
+
+type Option func(*checker)
+
+// NonCritical 检查失败只在报告中体现, 不影响就绪状态, 如日志、缓存等可降级的依赖
+func NonCritical() Option {
+	return func(c *checker) {
+		c.critical = false
+	}
+}
+
+// WithTimeout 覆盖默认的检查超时 (HEALTH_CHECK_TIMEOUT)
+func WithTimeout(d time.Duration) Option {
+	return func(c *checker) {
+		c.timeout = d
+	}
+}
+
+type checker struct {
+	name     string
+	fn       func(ctx context.Context) error
+	critical bool
+	timeout  time.Duration
+
+	mu        sync.Mutex // 同一时间只执行一次检查, 其他调用方等待并共用结果
+	last      *Component
+	expiresAt time.Time
+}
+
+var (
+	mu       sync.RWMutex
+	checkers = make(map[string]*checker)
+)
+
+// Register 注册检查函数, 同名时替换; fn 返回 nil 表示健康
+func Register(name string, fn func(ctx context.Context) error, opts ...Option) {
+	c := &checker{name: name, fn: fn, critical: true}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	checkers[name] = c
+
+	Log.Debugf("Health-Checker-Registered: Name=%s, Critical=%t", name, c.critical)
+}
+
+// Names 已注册的检查名称
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(checkers))
+
+	for name := range checkers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Live 存活状态: 进程能响应即为 UP, 不检查依赖, 避免依赖故障导致所有实例被重启
+func Live() *Report {
+	return &Report{Status: StatusUp, Components: map[string]*Component{}}
+}
+
+// Ready 并发执行所有检查 (结果缓存 HEALTH_CACHE_TTL), 汇总为就绪状态
+func Ready(ctx context.Context) *Report {
+	mu.RLock()
+
+	list := make([]*checker, 0, len(checkers))
+
+	for _, c := range checkers {
+		list = append(list, c)
+	}
+
+	mu.RUnlock()
+
+	report := &Report{Status: StatusUp, Components: make(map[string]*Component, len(list))}
+
+	var wg sync.WaitGroup
+	var rmu sync.Mutex
+
+	for _, c := range list {
+		wg.Add(1)
+
+		go func(c *checker) {
+			defer wg.Done()
+
+			comp := c.check(ctx)
+
+			rmu.Lock()
+			defer rmu.Unlock()
+
+			report.Components[c.name] = comp
+
+			if comp.Critical && comp.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(c)
+	}
+
+	wg.Wait()
+
+	if single.Draining() {
+		report.Status = StatusDown
+		report.Draining = true
+	}
+
+	return report
+}
+
+// Check 单个组件的状态, 未注册时返回 nil
+func Check(ctx context.Context, name string) *Component {
+	mu.RLock()
+	c := checkers[name]
+	mu.RUnlock()
+
+	if c == nil {
+		return nil
+	}
+
+	return c.check(ctx)
+}
+
+func (c *checker) check(ctx context.Context) *Component {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.last != nil && time.Now().Before(c.expiresAt) {
+		return c.last
+	}
+
+	timeout := c.timeout
+
+	if timeout <= 0 {
+		timeout = Settings.Timeout
+	}
+
+	// 结果会被缓存, 不受调用方 (如断开的探针请求) 取消的影响
+	ctx, cancelFunc := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+
+	defer cancelFunc()
+
+	start := time.Now()
+	err := c.run(ctx)
+	latency := time.Since(start)
+
+	comp := &Component{
+		Status:    StatusUp,
+		Critical:  c.critical,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+		CheckedAt: start.Format(time.RFC3339),
+	}
+
+	if err != nil {
+		comp.Status = StatusDown
+		comp.Error = err.Error()
+
+		Log.Warnf("Health-Check-Failed: Name=%s, Latency=%s, Error=%s", c.name, latency, err.Error())
+	}
+
+	c.last = comp
+	c.expiresAt = time.Now().Add(Settings.CacheTTL)
+
+	return comp
+}
+
+// run 检查函数不响应 ctx 时也按超时返回
+func (c *checker) run(ctx context.Context) error {
+	result := make(chan error, 1)
+
+	go func() {
+		result <- c.fn(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
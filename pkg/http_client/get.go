@@ -1,6 +1,7 @@
 package http_client
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,9 +10,15 @@ import (
 	"moul.io/http2curl"
 
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/tracing"
 )
 
 func HttpGet(reqUrl string) ([]byte, error) {
+	return HttpGetContext(context.Background(), reqUrl)
+}
+
+// HttpGetContext 携带 ctx 的 traceparent, 并创建 client Span
+func HttpGetContext(ctx context.Context, reqUrl string) ([]byte, error) {
 
 	myHttpClient := &http.Client{
 		Transport: defaultTransport(),
@@ -23,10 +30,14 @@ func HttpGet(reqUrl string) ([]byte, error) {
 
 	var req *http.Request
 
-	req, _ = http.NewRequest("GET", reqUrl, nil)
+	req, _ = http.NewRequestWithContext(ctx, "GET", reqUrl, nil)
+
+	req, span := tracing.StartHTTPClient(req)
 
 	resp, err := myHttpClient.Do(req)
 
+	tracing.EndHTTPClient(span, resp, err)
+
 	command, _ := http2curl.GetCurlCommand(req)
 	commandCurl := command.String()
 
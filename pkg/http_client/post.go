@@ -2,6 +2,7 @@ package http_client
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,9 +11,15 @@ import (
 	"moul.io/http2curl"
 
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/tracing"
 )
 
 func HttpPost(reqUrl string, contentType string, data []byte) ([]byte, error) {
+	return HttpPostContext(context.Background(), reqUrl, contentType, data)
+}
+
+// HttpPostContext 携带 ctx 的 traceparent, 并创建 client Span
+func HttpPostContext(ctx context.Context, reqUrl string, contentType string, data []byte) ([]byte, error) {
 
 	myHttpClient := &http.Client{
 		Transport: defaultTransport(),
@@ -24,10 +31,14 @@ func HttpPost(reqUrl string, contentType string, data []byte) ([]byte, error) {
 
 	var req *http.Request
 
-	req, _ = http.NewRequest("POST", reqUrl, bytes.NewBuffer(data))
+	req, _ = http.NewRequestWithContext(ctx, "POST", reqUrl, bytes.NewBuffer(data))
+
+	req, span := tracing.StartHTTPClient(req)
 
 	resp, err := myHttpClient.Do(req)
 
+	tracing.EndHTTPClient(span, resp, err)
+
 	command, _ := http2curl.GetCurlCommand(req)
 	commandCurl := command.String()
 
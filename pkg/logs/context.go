@@ -0,0 +1,123 @@
+package logs
+
+import (
+	"context"
+	"sync"
+)
+
+// RequestIDHeader 请求 ID 的请求头 / 响应头, gRPC metadata 中为小写的 x-request-id
+const RequestIDHeader = "X-Request-ID"
+
+// Fields 与请求关联的日志字段, 由 middlewares.RequestID 放入请求的 context; Log.Ctx(ctx) 输出这些字段
+type Fields struct {
+	RequestID string
+	Route     string // 路由模板或 gRPC 方法名
+
+	mu     sync.RWMutex
+	userID string
+	lookup func() string // 请求处理过程中登录信息可能稍后才写入, 输出时再读取
+}
+
+// SetUserID 设置用户 ID, 覆盖 SetUserLookup
+func (f *Fields) SetUserID(userID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.userID = userID
+	f.lookup = nil
+}
+
+// SetUserLookup 输出日志时调用 fn 读取用户 ID, 如 RequestContext.UserID
+func (f *Fields) SetUserLookup(fn func() string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.lookup = fn
+}
+
+func (f *Fields) UserID() string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.lookup != nil {
+		return f.lookup()
+	}
+
+	return f.userID
+}
+
+type fieldsKey struct{}
+
+func NewContext(ctx context.Context, f *Fields) context.Context {
+	return context.WithValue(ctx, fieldsKey{}, f)
+}
+
+// FieldsFromContext 没有时返回 nil
+func FieldsFromContext(ctx context.Context) *Fields {
+	f, _ := ctx.Value(fieldsKey{}).(*Fields)
+
+	return f
+}
+
+// RequestIDFromContext 没有时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	if f := FieldsFromContext(ctx); f != nil {
+		return f.RequestID
+	}
+
+	return ""
+}
+
+type contextField struct {
+	name string
+	fn   func(ctx context.Context) string
+}
+
+var (
+	contextFieldsMu sync.RWMutex
+	contextFields   []contextField
+)
+
+// AddContextField 注册从 ctx 读取的日志字段, 如 tracing 注册的 trace_id; fn 返回空字符串时不输出
+func AddContextField(name string, fn func(ctx context.Context) string) {
+	contextFieldsMu.Lock()
+	defer contextFieldsMu.Unlock()
+
+	contextFields = append(contextFields, contextField{name: name, fn: fn})
+}
+
+// Ctx 附加 ctx 中的请求 ID、用户 ID、路由及 AddContextField 注册的字段:
+//
+//	Log.Ctx(c.Request.Context()).Infof("Order-Created: OrderId=%d", id)
+func (l Logger) Ctx(ctx context.Context) Logger {
+	if ctx == nil {
+		return l
+	}
+
+	c := l.Logger.With()
+
+	if f := FieldsFromContext(ctx); f != nil {
+		if f.RequestID != "" {
+			c = c.Str("request_id", f.RequestID)
+		}
+
+		if userID := f.UserID(); userID != "" {
+			c = c.Str("user_id", userID)
+		}
+
+		if f.Route != "" {
+			c = c.Str("route", f.Route)
+		}
+	}
+
+	contextFieldsMu.RLock()
+	defer contextFieldsMu.RUnlock()
+
+	for _, field := range contextFields {
+		if v := field.fn(ctx); v != "" {
+			c = c.Str(field.name, v)
+		}
+	}
+
+	return Logger{Logger: c.Logger()}
+}
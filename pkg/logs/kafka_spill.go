@@ -0,0 +1,220 @@
+package logs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// spillStore Kafka 不可用时保存日志的目录, 每条记录为 4 字节长度 + 内容; 只在 KafkaWriter 的发送 goroutine 中使用
+type spillStore struct {
+	dir     string
+	maxSize int64
+	size    atomic.Int64 // 目录中各文件的总字节数
+	file    *os.File     // 正在写入的文件, 补发前关闭
+}
+
+func openSpill(dir string, maxSize int64) (*spillStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &spillStore{dir: dir, maxSize: maxSize}
+
+	// 上次退出时未补发的文件
+	s.size.Store(s.dirSize())
+
+	return s, nil
+}
+
+func (s *spillStore) files() []string {
+	names, _ := filepath.Glob(filepath.Join(s.dir, "*.spill"))
+
+	sort.Strings(names)
+
+	return names
+}
+
+func (s *spillStore) dirSize() int64 {
+	var total int64
+
+	for _, name := range s.files() {
+		if info, err := os.Stat(name); err == nil {
+			total += info.Size()
+		}
+	}
+
+	return total
+}
+
+func (s *spillStore) empty() bool {
+	return s.size.Load() == 0
+}
+
+// append 写入 batch, 超过 maxSize 时之后的记录不再写入; 返回写入的条数
+func (s *spillStore) append(batch [][]byte) (int, error) {
+	if s.file == nil {
+		// 文件名按时间排序, 补发时先发早的
+		file, err := os.OpenFile(filepath.Join(s.dir, fmt.Sprintf("%020d.spill", time.Now().UnixNano())), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+
+		if err != nil {
+			return 0, err
+		}
+
+		s.file = file
+	}
+
+	w := bufio.NewWriter(s.file)
+
+	var header [4]byte
+
+	n := 0
+
+	for _, data := range batch {
+		size := int64(len(header) + len(data))
+
+		if s.size.Load()+size > s.maxSize {
+			break
+		}
+
+		binary.BigEndian.PutUint32(header[:], uint32(len(data)))
+
+		_, _ = w.Write(header[:])
+		_, _ = w.Write(data)
+
+		s.size.Add(size)
+		n++
+	}
+
+	if err := w.Flush(); err != nil {
+		s.size.Store(s.dirSize())
+
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// replay 依次读取各文件并以 batchSize 条为一批调用 send; 失败时文件只保留未发送的记录
+func (s *spillStore) replay(batchSize int, send func(batch [][]byte) error) error {
+	// 之后落盘的日志写入新文件
+	s.close()
+
+	defer func() {
+		s.size.Store(s.dirSize())
+	}()
+
+	for _, name := range s.files() {
+		records, err := readRecords(name)
+
+		if err != nil {
+			// 文件末尾不完整 (如写入时进程退出), 只补发完整的记录
+			log.Printf("Kafka-Log-Spill-Corrupted: File=%s, Records=%d, Error=%v", name, len(records), err)
+		}
+
+		for i := 0; i < len(records); i += batchSize {
+			end := min(i+batchSize, len(records))
+
+			if err := send(records[i:end]); err != nil {
+				if i > 0 {
+					if rerr := writeRecords(name, records[i:]); rerr != nil {
+						return errors.Join(err, rerr)
+					}
+				}
+
+				return err
+			}
+		}
+
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *spillStore) close() {
+	if s.file != nil {
+		_ = s.file.Close()
+
+		s.file = nil
+	}
+}
+
+func readRecords(name string) ([][]byte, error) {
+	file, err := os.Open(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+
+	var (
+		records [][]byte
+		header  [4]byte
+	)
+
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, nil
+			}
+
+			return records, err
+		}
+
+		data := make([]byte, binary.BigEndian.Uint32(header[:]))
+
+		if _, err := io.ReadFull(r, data); err != nil {
+			return records, err
+		}
+
+		records = append(records, data)
+	}
+}
+
+// writeRecords 以临时文件替换 name, 避免写入一半时丢失记录
+func writeRecords(name string, records [][]byte) error {
+	tmp := name + ".tmp"
+
+	file, err := os.Create(tmp)
+
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+
+	var header [4]byte
+
+	for _, data := range records {
+		binary.BigEndian.PutUint32(header[:], uint32(len(data)))
+
+		_, _ = w.Write(header[:])
+		_, _ = w.Write(data)
+	}
+
+	if err := w.Flush(); err != nil {
+		_ = file.Close()
+
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
@@ -3,41 +3,363 @@ package logs
 import (
 	"context"
 	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 )
 
+// 队列满时的处理方式 (LOG_KAFKA_OVERFLOW)
+const (
+	KafkaOverflowBlock      = "block"       // 等待队列有空位, 会阻塞打日志的 goroutine
+	KafkaOverflowDropOldest = "drop_oldest" // 丢弃队列中最早的一条
+	KafkaOverflowDropNewest = "drop_newest" // 丢弃当前这条
+)
+
+// KafkaWriterStats 日志行数的累计值, 用于监控
+type KafkaWriterStats struct {
+	Sent       int64 `json:"sent"`
+	Dropped    int64 `json:"dropped"`  // 队列满、落盘空间不足或关闭后写入而丢弃
+	Spilled    int64 `json:"spilled"`  // Kafka 不可用时写入磁盘
+	Replayed   int64 `json:"replayed"` // 从磁盘补发
+	Failed     int64 `json:"failed"`   // 发送失败的批次数
+	Queued     int   `json:"queued"`
+	SpillBytes int64 `json:"spill_bytes"`
+}
+
+// KafkaWriter 日志先放入有界队列, 由一个 goroutine 批量发送; Kafka 不可用时落盘, 恢复后补发
 type KafkaWriter struct {
 	writer *kafka.Writer
+	topic  string
+
+	overflow      string
+	batchSize     int
+	batchTimeout  time.Duration
+	writeTimeout  time.Duration
+	retryInterval time.Duration
+
+	queue chan []byte
+	spill *spillStore // 为 nil 时发送失败的日志直接丢弃
+
+	// unavailable 上次发送失败, 之后的日志直接落盘, 直到补发成功; 只在发送 goroutine 中读写
+	unavailable bool
+
+	closed    atomic.Bool
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+
+	sent, dropped, spilled, replayed, failed atomic.Int64
 }
 
-func NewKafkaWriter(brokers []string, topic string) *KafkaWriter {
-	return &KafkaWriter{
-		writer: kafka.NewWriter(kafka.WriterConfig{
-			Brokers:      brokers,
-			Topic:        topic,
+var activeKafka atomic.Pointer[KafkaWriter]
+
+func NewKafkaWriter(conf *LogConf) *KafkaWriter {
+	kw := &KafkaWriter{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(strings.Split(conf.LogKafkaServer, ",")...),
+			Topic:        conf.LogKafkaTopic,
 			Balancer:     &kafka.LeastBytes{},
-			BatchSize:    100,                    // 批量条数
-			BatchBytes:   10 * 1024,              // 批量最大字节
-			BatchTimeout: 500 * time.Millisecond, // 最长等待时间
-		}),
+			BatchSize:    Max(conf.LogKafkaBatchSize, 1),
+			BatchTimeout: 10 * time.Millisecond, // 已在队列中攒批, 不再等待
+			WriteTimeout: conf.LogKafkaWriteTimeout,
+			MaxAttempts:  3,
+		},
+		topic:         conf.LogKafkaTopic,
+		overflow:      conf.LogKafkaOverflow,
+		batchSize:     Max(conf.LogKafkaBatchSize, 1),
+		batchTimeout:  conf.LogKafkaBatchTimeout,
+		writeTimeout:  conf.LogKafkaWriteTimeout,
+		retryInterval: conf.LogKafkaRetryInterval,
+		queue:         make(chan []byte, Max(conf.LogKafkaQueueSize, 1)),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	if kw.batchTimeout <= 0 {
+		kw.batchTimeout = 500 * time.Millisecond
 	}
+
+	if kw.writeTimeout <= 0 {
+		kw.writeTimeout = 5 * time.Second
+	}
+
+	if kw.retryInterval <= 0 {
+		kw.retryInterval = 10 * time.Second
+	}
+
+	if conf.LogKafkaSpillDir != "" {
+		spill, err := openSpill(conf.LogKafkaSpillDir, int64(conf.LogKafkaSpillMaxSize)*1024*1024)
+
+		if err != nil {
+			// 不能写入 Log, 避免递归
+			log.Printf("Kafka-Log-Spill-Error: Dir=%s, Error=%v", conf.LogKafkaSpillDir, err)
+		} else {
+			kw.spill = spill
+		}
+	}
+
+	// 重新创建日志 (OnChange) 时关闭之前的 writer; 两者使用同一个落盘目录, 之前的 writer 退出 (发送完队列并关闭落盘文件) 后
+	// 才启动发送, 避免补发时读取或删除其正在写入的文件; 期间的日志留在队列中
+	prev := activeKafka.Swap(kw)
+
+	go func() {
+		if prev != nil {
+			_ = prev.Close(context.Background())
+		}
+
+		kw.run()
+	}()
+
+	return kw
 }
 
 // 实现 io.Writer 接口
 func (kw *KafkaWriter) Write(p []byte) (n int, err error) {
-	// 异步写入
-	go func(data []byte) {
-		err := kw.writer.WriteMessages(context.Background(), kafka.Message{
-			Value: data,
-		})
+	if kw.closed.Load() {
+		kw.dropped.Add(1)
 
-		if err != nil {
-			// 可选：打印或收集发送失败日志
-			log.Printf("Kafka write error: %v", err)
+		return len(p), nil
+	}
+
+	// 拷贝 p 避免被覆盖
+	data := append([]byte(nil), p...)
+
+	switch kw.overflow {
+	case KafkaOverflowBlock:
+		select {
+		case kw.queue <- data:
+		case <-kw.stop:
+			kw.dropped.Add(1)
+		}
+	case KafkaOverflowDropNewest:
+		select {
+		case kw.queue <- data:
+		default:
+			kw.dropped.Add(1)
 		}
-	}(append([]byte(nil), p...)) // 拷贝 p 避免被覆盖
+	default:
+		for {
+			select {
+			case kw.queue <- data:
+				return len(p), nil
+			default:
+			}
+
+			select {
+			case <-kw.queue:
+				kw.dropped.Add(1)
+			default:
+			}
+		}
+	}
 
 	return len(p), nil
 }
+
+func (kw *KafkaWriter) Stats() KafkaWriterStats {
+	stats := KafkaWriterStats{
+		Sent:     kw.sent.Load(),
+		Dropped:  kw.dropped.Load(),
+		Spilled:  kw.spilled.Load(),
+		Replayed: kw.replayed.Load(),
+		Failed:   kw.failed.Load(),
+		Queued:   len(kw.queue),
+	}
+
+	if kw.spill != nil {
+		stats.SpillBytes = kw.spill.size.Load()
+	}
+
+	return stats
+}
+
+// Close 发送队列中剩余的日志 (Kafka 不可用时落盘) 后关闭, 之后写入的日志丢弃
+func (kw *KafkaWriter) Close(ctx context.Context) error {
+	kw.closeOnce.Do(func() {
+		kw.closed.Store(true)
+
+		close(kw.stop)
+	})
+
+	select {
+	case <-kw.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// KafkaStats 当前 Kafka 日志 writer 的统计, 未启用 (LOG_OUTPUT 不含 kafka) 时 ok 为 false
+func KafkaStats() (stats KafkaWriterStats, ok bool) {
+	kw := activeKafka.Load()
+
+	if kw == nil {
+		return stats, false
+	}
+
+	return kw.Stats(), true
+}
+
+// CloseKafkaWriter 关闭当前 Kafka 日志 writer, 由 boot 注册为 PriorityLogger 阶段的关闭钩子
+func CloseKafkaWriter(ctx context.Context) error {
+	kw := activeKafka.Load()
+
+	if kw == nil {
+		return nil
+	}
+
+	return kw.Close(ctx)
+}
+
+func (kw *KafkaWriter) run() {
+	defer close(kw.done)
+
+	// 包含之前的 writer 退出时落盘的日志
+	if kw.spill != nil {
+		kw.spill.size.Store(kw.spill.dirSize())
+	}
+
+	ticker := time.NewTicker(kw.batchTimeout)
+	retry := time.NewTicker(kw.retryInterval)
+
+	defer ticker.Stop()
+	defer retry.Stop()
+
+	batch := make([][]byte, 0, kw.batchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		kw.send(batch)
+
+		batch = make([][]byte, 0, kw.batchSize)
+	}
+
+	for {
+		select {
+		case data := <-kw.queue:
+			batch = append(batch, data)
+
+			if len(batch) >= kw.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-retry.C:
+			kw.replay()
+		case <-kw.stop:
+			for {
+				select {
+				case data := <-kw.queue:
+					batch = append(batch, data)
+
+					if len(batch) >= kw.batchSize {
+						flush()
+					}
+				default:
+					flush()
+
+					// 未补发的日志留在磁盘上, 下次启动后补发
+					if kw.spill != nil {
+						kw.spill.close()
+					}
+
+					_ = kw.writer.Close()
+
+					return
+				}
+			}
+		}
+	}
+}
+
+func (kw *KafkaWriter) write(batch [][]byte) error {
+	msgs := make([]kafka.Message, len(batch))
+
+	for i, data := range batch {
+		msgs[i] = kafka.Message{Value: data}
+	}
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), kw.writeTimeout)
+	defer cancelFunc()
+
+	return kw.writer.WriteMessages(ctx, msgs...)
+}
+
+func (kw *KafkaWriter) send(batch [][]byte) {
+	// Kafka 不可用时直接落盘, 由 replay 探测是否恢复
+	if kw.spill != nil && kw.unavailable {
+		kw.spillBatch(batch)
+
+		return
+	}
+
+	if err := kw.write(batch); err != nil {
+		kw.failed.Add(1)
+
+		log.Printf("Kafka-Log-Write-Error: Topic=%s, Lines=%d, Error=%v", kw.topic, len(batch), err)
+
+		if kw.spill == nil {
+			kw.dropped.Add(int64(len(batch)))
+
+			return
+		}
+
+		kw.unavailable = true
+		kw.spillBatch(batch)
+
+		return
+	}
+
+	kw.sent.Add(int64(len(batch)))
+}
+
+func (kw *KafkaWriter) spillBatch(batch [][]byte) {
+	n, err := kw.spill.append(batch)
+
+	kw.spilled.Add(int64(n))
+	kw.dropped.Add(int64(len(batch) - n))
+
+	if err != nil {
+		log.Printf("Kafka-Log-Spill-Error: Dir=%s, Error=%v", kw.spill.dir, err)
+	}
+}
+
+// replay 按写入顺序补发落盘的日志, 成功后恢复直接发送
+func (kw *KafkaWriter) replay() {
+	if kw.spill == nil {
+		return
+	}
+
+	if kw.spill.empty() {
+		kw.unavailable = false
+
+		return
+	}
+
+	err := kw.spill.replay(kw.batchSize, func(batch [][]byte) error {
+		if err := kw.write(batch); err != nil {
+			return err
+		}
+
+		kw.replayed.Add(int64(len(batch)))
+
+		return nil
+	})
+
+	if err != nil {
+		kw.failed.Add(1)
+		kw.unavailable = true
+
+		log.Printf("Kafka-Log-Replay-Error: Topic=%s, SpillBytes=%d, Error=%v", kw.topic, kw.spill.size.Load(), err)
+
+		return
+	}
+
+	kw.unavailable = false
+}
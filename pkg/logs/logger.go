@@ -48,19 +48,11 @@ func NewLogger(conf *LogConf) Logger {
 	}
 
 	if fileEnable {
-		rotate := &lumberjack.Logger{
-			Compress:   true, // gzip 压缩旧文件
-			Filename:   conf.LogFilePath,
-			MaxSize:    Max(int(conf.LogFileMaxSize), 10), // MB，超过后切分
-			MaxBackups: Max(conf.LogFileMaxBackups, 10),   // 最多保留 10 个旧文件
-			MaxAge:     Max(int(conf.LogFileMaxAge), 30),  // 保留 30 天
-		}
-
-		writers = append(writers, rotate)
+		writers = append(writers, newRotate(conf.LogFilePath, conf))
 	}
 
 	if kafkaEnable {
-		kWriter := NewKafkaWriter(strings.Split(conf.LogKafkaServer, ","), conf.LogKafkaTopic)
+		kWriter := NewKafkaWriter(conf)
 
 		writers = append(writers, kWriter)
 	}
@@ -100,6 +92,29 @@ func NewLogger(conf *LogConf) Logger {
 	return logger
 }
 
+func newRotate(path string, conf *LogConf) *lumberjack.Logger {
+	return &lumberjack.Logger{
+		Compress:   true, // gzip 压缩旧文件
+		Filename:   path,
+		MaxSize:    Max(int(conf.LogFileMaxSize), 10), // MB，超过后切分
+		MaxBackups: Max(conf.LogFileMaxBackups, 10),   // 最多保留 10 个旧文件
+		MaxAge:     Max(int(conf.LogFileMaxAge), 30),  // 保留 30 天
+	}
+}
+
+// NewFileLogger 只写入 path 的 JSON 日志, 按 conf 的 LOG_FILE_MAX_* 切分, 如单独的访问日志
+func NewFileLogger(path string, conf *LogConf) Logger {
+	l := zerolog.New(newRotate(path, conf)).
+		With().
+		Str("app", os.Getenv("APP_NAME")).
+		Str("env", os.Getenv("ENV")).
+		Logger()
+
+	return Logger{
+		Logger: l,
+	}
+}
+
 func Max(a, b int) int {
 	if a > b {
 		return a
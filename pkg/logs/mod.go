@@ -52,6 +52,17 @@ type LogConf struct {
 	LogFileMaxAge     AgeDAY `mapstructure:"LOG_FILE_MAX_AGE" json:"log_file_max_age"`         // 30
 	LogKafkaServer    string `mapstructure:"LOG_KAFKA_SERVER" json:"log_kafka_server"`         // 127.0.0.1:9092
 	LogKafkaTopic     string `mapstructure:"LOG_KAFKA_TOPIC" json:"log_kafka_topic"`           // app_log
+
+	// 日志先放入长度为 LogKafkaQueueSize 的队列, 攒够 LogKafkaBatchSize 条或每隔 LogKafkaBatchTimeout 发送一次
+	LogKafkaQueueSize    int           `mapstructure:"LOG_KAFKA_QUEUE_SIZE" json:"log_kafka_queue_size"`       // 10000
+	LogKafkaBatchSize    int           `mapstructure:"LOG_KAFKA_BATCH_SIZE" json:"log_kafka_batch_size"`       // 100
+	LogKafkaBatchTimeout time.Duration `mapstructure:"LOG_KAFKA_BATCH_TIMEOUT" json:"log_kafka_batch_timeout"` // 500ms
+	LogKafkaWriteTimeout time.Duration `mapstructure:"LOG_KAFKA_WRITE_TIMEOUT" json:"log_kafka_write_timeout"` // 5s
+	LogKafkaOverflow     string        `mapstructure:"LOG_KAFKA_OVERFLOW" json:"log_kafka_overflow"`           // 队列满时: block, drop_oldest, drop_newest
+	// Kafka 不可用时写入 LogKafkaSpillDir (为空时丢弃), 每隔 LogKafkaRetryInterval 重试并补发
+	LogKafkaSpillDir      string        `mapstructure:"LOG_KAFKA_SPILL_DIR" json:"log_kafka_spill_dir"`           // logs/kafka-spill
+	LogKafkaSpillMaxSize  SizeMB        `mapstructure:"LOG_KAFKA_SPILL_MAX_SIZE" json:"log_kafka_spill_max_size"` // 100 (MB)
+	LogKafkaRetryInterval time.Duration `mapstructure:"LOG_KAFKA_RETRY_INTERVAL" json:"log_kafka_retry_interval"` // 10s
 }
 
 var LogSetting = &LogConf{
@@ -62,6 +73,15 @@ var LogSetting = &LogConf{
 	LogFileMaxSize:    20, // 单位: MB，超过后切分
 	LogFileMaxBackups: 10, // 最多保留 10 个旧文件
 	LogFileMaxAge:     30, // 保留 30 天
+
+	LogKafkaQueueSize:     10000,
+	LogKafkaBatchSize:     100,
+	LogKafkaBatchTimeout:  time.Millisecond * 500,
+	LogKafkaWriteTimeout:  time.Second * 5,
+	LogKafkaOverflow:      KafkaOverflowDropOldest,
+	LogKafkaSpillDir:      "logs/kafka-spill",
+	LogKafkaSpillMaxSize:  100,
+	LogKafkaRetryInterval: time.Second * 10,
 }
 
 var Log Logger
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 未匹配到路由 (404) 的请求使用同一个 route 标签, 避免按原始路径产生大量序列
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = NewCounter("http_requests_total", "HTTP 请求数", "method", "route", "status")
+	httpDuration = NewHistogram("http_request_duration_seconds", "HTTP 请求耗时", nil, "method", "route", "status")
+	httpInFlight = NewGauge("http_requests_in_flight", "正在处理的 HTTP 请求数")
+)
+
+// Middleware 按路由模板 (如 /users/:id) 和状态码记录请求数和耗时
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Settings.Enable {
+			c.Next()
+
+			return
+		}
+
+		start := time.Now()
+
+		httpInFlight.Inc()
+
+		defer httpInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		status := strconv.Itoa(c.Writer.Status())
+
+		httpRequests.Inc(c.Request.Method, route, status)
+		httpDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route, status)
+	}
+}
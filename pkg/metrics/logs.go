@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+)
+
+func init() {
+	// LOG_OUTPUT 不含 kafka 时不输出
+	NewCounterFunc("log_kafka_lines_total", "发送到 Kafka 的日志行数", []string{"result"}, func() []Sample {
+		s, ok := KafkaStats()
+
+		if !ok {
+			return nil
+		}
+
+		return []Sample{
+			{LabelValues: []string{"sent"}, Value: float64(s.Sent)},
+			{LabelValues: []string{"dropped"}, Value: float64(s.Dropped)},
+			{LabelValues: []string{"spilled"}, Value: float64(s.Spilled)},
+			{LabelValues: []string{"replayed"}, Value: float64(s.Replayed)},
+		}
+	})
+	NewCounterFunc("log_kafka_write_failures_total", "日志发送到 Kafka 失败的批次数", nil, func() []Sample {
+		if s, ok := KafkaStats(); ok {
+			return one(float64(s.Failed))
+		}
+
+		return nil
+	})
+	NewGaugeFunc("log_kafka_queue_length", "等待发送到 Kafka 的日志行数", nil, func() []Sample {
+		if s, ok := KafkaStats(); ok {
+			return one(float64(s.Queued))
+		}
+
+		return nil
+	})
+	NewGaugeFunc("log_kafka_spill_bytes", "Kafka 不可用时落盘的日志字节数", nil, func() []Sample {
+		if s, ok := KafkaStats(); ok {
+			return one(float64(s.SpillBytes))
+		}
+
+		return nil
+	})
+}
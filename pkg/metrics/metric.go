@@ -0,0 +1,326 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// Sample 采集函数返回的一个样本, LabelValues 与注册时的 labelNames 一一对应
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+type desc struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+}
+
+func (d *desc) header(b *bytes.Buffer) {
+	b.WriteString("# HELP " + d.name + " " + escapeHelp(d.help) + "\n")
+	b.WriteString("# TYPE " + d.name + " " + d.typ + "\n")
+}
+
+// labelKey 标签值拼接为 map 的键, 标签个数不匹配时补空或截断
+func (d *desc) labelKey(labelValues []string) string {
+	if len(labelValues) != len(d.labelNames) {
+		fixed := make([]string, len(d.labelNames))
+
+		copy(fixed, labelValues)
+
+		labelValues = fixed
+	}
+
+	return strings.Join(labelValues, "\xff")
+}
+
+// series 一组标签值, extra 为附加的标签 (如直方图的 le)
+func (d *desc) series(b *bytes.Buffer, suffix string, key string, extraName string, extraValue string, v float64) {
+	b.WriteString(d.name + suffix)
+
+	var values []string
+
+	if len(d.labelNames) > 0 {
+		values = strings.Split(key, "\xff")
+	}
+
+	if len(values) > 0 || extraName != "" {
+		b.WriteByte('{')
+
+		for i, name := range d.labelNames {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+
+			b.WriteString(name + `="` + escapeLabel(values[i]) + `"`)
+		}
+
+		if extraName != "" {
+			if len(values) > 0 {
+				b.WriteByte(',')
+			}
+
+			b.WriteString(extraName + `="` + extraValue + `"`)
+		}
+
+		b.WriteByte('}')
+	}
+
+	b.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// CounterVec 只增不减的计数, 如请求数、错误数
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter 注册计数器, 同名的计数器已存在时返回已有的
+func NewCounter(name string, help string, labelNames ...string) *CounterVec {
+	return register(&CounterVec{desc: desc{name: name, help: help, typ: typeCounter, labelNames: labelNames}, values: make(map[string]float64)})
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+
+	key := c.labelKey(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[key] += v
+}
+
+func (c *CounterVec) write(b *bytes.Buffer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header(b)
+
+	for _, key := range sortedKeys(c.values) {
+		c.series(b, "", key, "", "", c.values[key])
+	}
+}
+
+// GaugeVec 可增可减的瞬时值, 如进行中的请求数、消费延迟
+type GaugeVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewGauge 注册 gauge, 同名的已存在时返回已有的
+func NewGauge(name string, help string, labelNames ...string) *GaugeVec {
+	return register(&GaugeVec{desc: desc{name: name, help: help, typ: typeGauge, labelNames: labelNames}, values: make(map[string]float64)})
+}
+
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	key := g.labelKey(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.values[key] = v
+}
+
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	key := g.labelKey(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.values[key] += v
+}
+
+func (g *GaugeVec) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+func (g *GaugeVec) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+// Delete 删除一组标签值, 如分区被回收后不再报告其消费延迟
+func (g *GaugeVec) Delete(labelValues ...string) {
+	key := g.labelKey(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.values, key)
+}
+
+func (g *GaugeVec) write(b *bytes.Buffer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.header(b)
+
+	for _, key := range sortedKeys(g.values) {
+		g.series(b, "", key, "", "", g.values[key])
+	}
+}
+
+type histogramValue struct {
+	counts []uint64 // 每个桶的计数 (非累积), 输出时累加
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec 耗时等分布, 输出 _bucket, _sum, _count
+type HistogramVec struct {
+	desc
+	once    sync.Once
+	buckets []float64 // 为空时使用 METRICS_BUCKETS
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+// NewHistogram 注册直方图, buckets 为空时使用 METRICS_BUCKETS (单位: 秒); 同名的已存在时返回已有的
+func NewHistogram(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return register(&HistogramVec{desc: desc{name: name, help: help, typ: typeHistogram, labelNames: labelNames}, buckets: buckets, values: make(map[string]*histogramValue)})
+}
+
+// 配置在包初始化之后加载, 第一次使用时才确定桶
+func (h *HistogramVec) resolveBuckets() {
+	h.once.Do(func() {
+		if len(h.buckets) == 0 {
+			h.buckets = DefaultBuckets()
+		}
+
+		sort.Float64s(h.buckets)
+	})
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.resolveBuckets()
+
+	key := h.labelKey(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hv, ok := h.values[key]
+
+	if !ok {
+		hv = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		hv.counts[i]++
+	}
+
+	hv.sum += v
+	hv.count++
+}
+
+func (h *HistogramVec) write(b *bytes.Buffer) {
+	h.resolveBuckets()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(b)
+
+	for _, key := range sortedKeys(h.values) {
+		hv := h.values[key]
+
+		var cumulative uint64
+
+		for i, upper := range h.buckets {
+			cumulative += hv.counts[i]
+
+			h.series(b, "_bucket", key, "le", formatFloat(upper), float64(cumulative))
+		}
+
+		h.series(b, "_bucket", key, "le", "+Inf", float64(hv.count))
+		h.series(b, "_sum", key, "", "", hv.sum)
+		h.series(b, "_count", key, "", "", float64(hv.count))
+	}
+}
+
+// funcMetric 抓取时调用 fn 取值, 用于连接池、消费延迟、运行时等已有统计
+type funcMetric struct {
+	desc
+	fn func() []Sample
+}
+
+// NewGaugeFunc 注册抓取时才取值的 gauge
+func NewGaugeFunc(name string, help string, labelNames []string, fn func() []Sample) {
+	register(&funcMetric{desc: desc{name: name, help: help, typ: typeGauge, labelNames: labelNames}, fn: fn})
+}
+
+// NewCounterFunc 注册抓取时才取值的计数器, fn 返回的值应单调递增
+func NewCounterFunc(name string, help string, labelNames []string, fn func() []Sample) {
+	register(&funcMetric{desc: desc{name: name, help: help, typ: typeCounter, labelNames: labelNames}, fn: fn})
+}
+
+func (f *funcMetric) write(b *bytes.Buffer) {
+	f.header(b)
+
+	samples := f.fn()
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		return strings.Join(samples[i].LabelValues, "\xff") < strings.Join(samples[j].LabelValues, "\xff")
+	})
+
+	for _, s := range samples {
+		f.series(b, "", f.labelKey(s.LabelValues), "", "", s.Value)
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
@@ -0,0 +1,120 @@
+// Package metrics Prometheus 文本格式 (text/plain; version=0.0.4) 的指标, 由 GET /metrics 输出.
+// 各子系统在包内注册自己的指标:
+//
+//	var sent = metrics.NewCounter("order_sent_total", "已发送的订单数", "channel")
+//
+//	sent.Inc("app")
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+)
+
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type MetricsConf struct {
+	Enable bool `mapstructure:"METRICS_ENABLE"`
+	// Buckets 耗时直方图默认的桶 (秒), 逗号分隔
+	Buckets string `mapstructure:"METRICS_BUCKETS"`
+}
+
+var Settings = &MetricsConf{
+	Enable:  true,
+	Buckets: "0.005,0.01,0.025,0.05,0.1,0.25,0.5,1,2.5,5,10",
+}
+
+// DefaultBuckets 解析 METRICS_BUCKETS
+func DefaultBuckets() []float64 {
+	var buckets []float64
+
+	for _, item := range strings.Split(Settings.Buckets, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(item, 64)
+
+		if err != nil {
+			Log.Warnf("Metrics-Bucket-Invalid: Bucket=%s, Error=%s", item, err.Error())
+
+			continue
+		}
+
+		buckets = append(buckets, v)
+	}
+
+	return buckets
+}
+
+type metric interface {
+	write(b *bytes.Buffer)
+	metricDesc() *desc
+}
+
+func (d *desc) metricDesc() *desc {
+	return d
+}
+
+var (
+	mu       sync.RWMutex
+	registry = make(map[string]metric)
+)
+
+// register 同名指标已存在且类型相同时返回已有的, 多处 (如 gkafka 与 gkafkav2) 可共用一个指标
+func register[M metric](m M) M {
+	mu.Lock()
+	defer mu.Unlock()
+
+	name := m.metricDesc().name
+
+	if existing, ok := registry[name]; ok {
+		if same, ok := existing.(M); ok {
+			return same
+		}
+
+		Log.Errorf("Metrics-Register-Conflict: Name=%s, Type=%s", name, m.metricDesc().typ)
+
+		return m
+	}
+
+	registry[name] = m
+
+	return m
+}
+
+// Write 按名称顺序输出所有指标
+func Write(b *bytes.Buffer) {
+	mu.RLock()
+
+	names := sortedKeys(registry)
+	list := make([]metric, 0, len(names))
+
+	for _, name := range names {
+		list = append(list, registry[name])
+	}
+
+	mu.RUnlock()
+
+	for _, m := range list {
+		m.write(b)
+	}
+}
+
+// Handler 输出所有指标的 http.Handler, 可挂在独立的端口上
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		var b bytes.Buffer
+
+		Write(&b)
+
+		w.Header().Set("Content-Type", ContentType)
+
+		_, _ = w.Write(b.Bytes())
+	})
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+var startTime = time.Now()
+
+var (
+	memMu     sync.Mutex
+	memStats  runtime.MemStats
+	memReadAt time.Time
+)
+
+// readMemStats ReadMemStats 会短暂 stop the world, 同一次抓取的多个指标共用一份结果
+func readMemStats() runtime.MemStats {
+	memMu.Lock()
+	defer memMu.Unlock()
+
+	if time.Since(memReadAt) > time.Second {
+		runtime.ReadMemStats(&memStats)
+
+		memReadAt = time.Now()
+	}
+
+	return memStats
+}
+
+func one(v float64) []Sample {
+	return []Sample{{Value: v}}
+}
+
+func init() {
+	NewGaugeFunc("go_info", "Go 版本", []string{"version"}, func() []Sample {
+		return []Sample{{LabelValues: []string{runtime.Version()}, Value: 1}}
+	})
+	NewGaugeFunc("go_goroutines", "当前的 goroutine 数", nil, func() []Sample {
+		return one(float64(runtime.NumGoroutine()))
+	})
+	NewGaugeFunc("go_memstats_alloc_bytes", "堆上已分配且仍在使用的字节数", nil, func() []Sample {
+		return one(float64(readMemStats().Alloc))
+	})
+	NewGaugeFunc("go_memstats_heap_inuse_bytes", "使用中的堆 span 字节数", nil, func() []Sample {
+		return one(float64(readMemStats().HeapInuse))
+	})
+	NewGaugeFunc("go_memstats_heap_objects", "堆上的对象数", nil, func() []Sample {
+		return one(float64(readMemStats().HeapObjects))
+	})
+	NewGaugeFunc("go_memstats_sys_bytes", "从操作系统获得的字节数", nil, func() []Sample {
+		return one(float64(readMemStats().Sys))
+	})
+	NewCounterFunc("go_memstats_alloc_bytes_total", "累计分配的堆字节数", nil, func() []Sample {
+		return one(float64(readMemStats().TotalAlloc))
+	})
+	NewCounterFunc("go_gc_cycles_total", "已完成的 GC 次数", nil, func() []Sample {
+		return one(float64(readMemStats().NumGC))
+	})
+	NewCounterFunc("go_gc_pause_seconds_total", "GC 累计暂停时间", nil, func() []Sample {
+		return one(time.Duration(readMemStats().PauseTotalNs).Seconds())
+	})
+	NewGaugeFunc("process_start_time_seconds", "进程启动时间 (unix 秒)", nil, func() []Sample {
+		return one(float64(startTime.UnixNano()) / 1e9)
+	})
+}
@@ -0,0 +1,164 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodyBuffer 保存请求体或响应体的前 max 个字节
+type bodyBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *bodyBuffer) write(p []byte) {
+	room := b.max - b.buf.Len()
+
+	if len(p) > room {
+		b.truncated = true
+		p = p[:max(room, 0)]
+	}
+
+	b.buf.Write(p)
+}
+
+// text 只返回 json / form / text / xml 内容, 截断时以 ... 结尾
+func (b *bodyBuffer) text(contentType string) (string, bool) {
+	if b.buf.Len() == 0 || !textual(contentType) {
+		return "", false
+	}
+
+	if b.truncated {
+		return b.buf.String() + "...", true
+	}
+
+	return b.buf.String(), true
+}
+
+func textual(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/") ||
+		strings.HasSuffix(contentType, "json") ||
+		strings.HasSuffix(contentType, "xml") ||
+		contentType == gin.MIMEPOSTForm
+}
+
+func filterFlags(contentType string) string {
+	contentType, _, _ = strings.Cut(contentType, ";")
+
+	return strings.TrimSpace(contentType)
+}
+
+// captureRequestBody 先读出请求体的前 limit+1 个字节, 再与剩余部分拼接后交给 handler
+func captureRequestBody(c *gin.Context, limit int) *bodyBuffer {
+	b := &bodyBuffer{max: limit}
+
+	if c.Request.Body == nil || c.Request.Body == http.NoBody || !textual(c.ContentType()) {
+		return b
+	}
+
+	body := c.Request.Body
+	head, err := io.ReadAll(io.LimitReader(body, int64(limit)+1))
+
+	b.write(head)
+
+	var rest io.Reader = body
+
+	if err != nil {
+		// handler 读到相同的错误
+		rest = errReader{err: err}
+	}
+
+	c.Request.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(head), rest), Closer: body}
+
+	return b
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// bodyWriter 写入响应时同时保存前 limit 个字节
+type bodyWriter struct {
+	gin.ResponseWriter
+	buf *bodyBuffer
+}
+
+func (w *bodyWriter) Write(p []byte) (int, error) {
+	w.buf.write(p)
+
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *bodyWriter) WriteString(s string) (int, error) {
+	w.buf.write([]byte(s))
+
+	return w.ResponseWriter.WriteString(s)
+}
+
+func captureResponseBody(c *gin.Context, limit int) *bodyBuffer {
+	b := &bodyBuffer{max: limit}
+
+	c.Writer = &bodyWriter{ResponseWriter: c.Writer, buf: b}
+
+	return b
+}
+
+// redactor 把 json 和 form 中指定字段的值替换为 ***, 字段名不区分大小写; 截断的内容同样适用
+type redactor struct {
+	jsonValue *regexp.Regexp
+	formValue *regexp.Regexp
+}
+
+func newRedactor(keys []string) *redactor {
+	if len(keys) == 0 {
+		return &redactor{}
+	}
+
+	quoted := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		quoted = append(quoted, regexp.QuoteMeta(key))
+	}
+
+	names := strings.Join(quoted, "|")
+
+	return &redactor{
+		jsonValue: regexp.MustCompile(`(?i)("(?:` + names + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`),
+		formValue: regexp.MustCompile(`(?i)((?:^|&)(?:` + names + `)=)[^&]*`),
+	}
+}
+
+func (r *redactor) body(s string, contentType string) string {
+	if contentType == gin.MIMEPOSTForm {
+		return r.form(s)
+	}
+
+	if r.jsonValue == nil {
+		return s
+	}
+
+	return r.jsonValue.ReplaceAllString(s, `${1}"***"`)
+}
+
+func (r *redactor) form(s string) string {
+	if r.formValue == nil {
+		return s
+	}
+
+	return r.formValue.ReplaceAllString(s, `${1}***`)
+}
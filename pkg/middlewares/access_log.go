@@ -2,11 +2,18 @@ package middlewares
 
 import (
 	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
 
+	"github.com/chunhui2001/zero4go/pkg/config"
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/tracing"
+	"github.com/chunhui2001/zero4go/pkg/upstream"
 )
 
 var DefaultLogFormatter = func(param gin.LogFormatterParams) string {
@@ -26,10 +33,6 @@ var DefaultLogFormatter = func(param gin.LogFormatterParams) string {
 	)
 }
 
-func init() {
-
-}
-
 func AccessLog(skips ...string) gin.HandlerFunc {
 	return Print(gin.LoggerConfig{
 		SkipPaths: skips,
@@ -60,7 +63,7 @@ func Print(conf gin.LoggerConfig) gin.HandlerFunc {
 
 		// Log only when path is not being skipped
 		if _, ok := skip[path]; !ok {
-			Log.Info(DefaultLogFormatter(LogParam(c, c.Writer.Status(), path, start)))
+			Log.Ctx(c.Request.Context()).Info(DefaultLogFormatter(LogParam(c, c.Writer.Status(), path, start)))
 		}
 	}
 }
@@ -78,7 +81,8 @@ func LogParam(c *gin.Context, code int, path string, start time.Time) gin.LogFor
 	param.TimeStamp = time.Now()
 	param.Latency = param.TimeStamp.Sub(start)
 
-	param.ClientIP = c.Request.RemoteAddr
+	// 只信任 SERVER_TRUSTED_PROXIES 中代理传入的 X-Forwarded-For / X-Real-IP
+	param.ClientIP = c.ClientIP()
 	param.Method = c.Request.Method
 	param.StatusCode = code
 	param.ErrorMessage = c.Errors.ByType(gin.ErrorTypePrivate).String()
@@ -93,3 +97,218 @@ func LogParam(c *gin.Context, code int, path string, start time.Time) gin.LogFor
 
 	return param
 }
+
+// 访问日志的输出格式 (SERVER_ACCESS_LOG_FORMAT)
+const (
+	AccessLogJSON = "json"
+	AccessLogText = "text"
+)
+
+type accessLog struct {
+	conf   config.AccessLogConf
+	skip   map[string]bool
+	fields []string
+	rates  map[string]float64
+	redact *redactor
+	file   *Logger // 为 nil 时写入应用日志
+}
+
+// NewAccessLog 按 conf 记录访问日志, 见 config.AccessLogConf
+func NewAccessLog(conf config.AccessLogConf) gin.HandlerFunc {
+	a := &accessLog{
+		conf:   conf,
+		skip:   make(map[string]bool),
+		rates:  make(map[string]float64),
+		redact: newRedactor(splitComma(conf.RedactKeys)),
+	}
+
+	for _, path := range splitComma(conf.SkipPaths) {
+		a.skip[path] = true
+	}
+
+	for _, name := range splitComma(conf.Fields) {
+		if !accessLogFields[name] {
+			Log.Warnf("AccessLog-Field-Unknown: Name=%s", name)
+
+			continue
+		}
+
+		a.fields = append(a.fields, name)
+	}
+
+	for _, item := range splitComma(conf.SampleRoutes) {
+		route, ratio, ok := strings.Cut(item, "=")
+
+		v, err := strconv.ParseFloat(strings.TrimSpace(ratio), 64)
+
+		if !ok || err != nil {
+			Log.Warnf("AccessLog-SampleRoute-Invalid: Value=%s", item)
+
+			continue
+		}
+
+		a.rates[strings.TrimSpace(route)] = v
+	}
+
+	if conf.File != "" {
+		l := NewFileLogger(conf.File, LogSetting)
+
+		a.file = &l
+	}
+
+	return a.handle
+}
+
+var accessLogFields = map[string]bool{
+	"client_ip": true, "method": true, "path": true, "query": true, "proto": true, "status": true, "latency": true,
+	"user_agent": true, "referer": true, "request_bytes": true, "response_bytes": true, "route": true,
+	"request_id": true, "user_id": true, "trace_id": true, "upstream": true, "error": true,
+}
+
+func splitComma(s string) []string {
+	var out []string
+
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+
+	return out
+}
+
+func (a *accessLog) handle(c *gin.Context) {
+	start := time.Now()
+	path := c.Request.URL.Path
+
+	if a.skip[path] {
+		c.Next()
+
+		return
+	}
+
+	var reqBody, respBody *bodyBuffer
+
+	if a.conf.Body {
+		reqBody = captureRequestBody(c, a.conf.BodyMaxSize)
+		respBody = captureResponseBody(c, a.conf.BodyMaxSize)
+	}
+
+	c.Next()
+
+	status := c.Writer.Status()
+
+	if !a.sampled(c.FullPath(), status) {
+		return
+	}
+
+	l := Log
+
+	if a.file != nil {
+		l = *a.file
+	}
+
+	if a.conf.Format == AccessLogText {
+		l.Ctx(c.Request.Context()).Info(DefaultLogFormatter(LogParam(c, status, path, start)))
+
+		return
+	}
+
+	e := l.Infoe()
+
+	for _, name := range a.fields {
+		a.field(e, c, name, start)
+	}
+
+	if reqBody != nil {
+		if body, ok := reqBody.text(c.ContentType()); ok {
+			e.Str("request_body", a.redact.body(body, c.ContentType()))
+		}
+	}
+
+	if respBody != nil {
+		contentType := filterFlags(c.Writer.Header().Get("Content-Type"))
+
+		if body, ok := respBody.text(contentType); ok {
+			e.Str("response_body", a.redact.body(body, contentType))
+		}
+	}
+
+	e.Msg("Access")
+}
+
+// sampled 状态码 >= 500 的请求总是记录
+func (a *accessLog) sampled(route string, status int) bool {
+	if status >= 500 {
+		return true
+	}
+
+	ratio, ok := a.rates[route]
+
+	if !ok {
+		ratio = a.conf.SampleRatio
+	}
+
+	if ratio >= 1 {
+		return true
+	}
+
+	return ratio > 0 && rand.Float64() < ratio
+}
+
+func (a *accessLog) field(e *zerolog.Event, c *gin.Context, name string, start time.Time) {
+	switch name {
+	case "client_ip":
+		e.Str(name, c.ClientIP())
+	case "method":
+		e.Str(name, c.Request.Method)
+	case "path":
+		e.Str(name, c.Request.URL.Path)
+	case "query":
+		if raw := c.Request.URL.RawQuery; raw != "" {
+			e.Str(name, a.redact.form(raw))
+		}
+	case "proto":
+		e.Str(name, c.Request.Proto)
+	case "status":
+		e.Int(name, c.Writer.Status())
+	case "latency":
+		e.Dur(name, time.Since(start))
+	case "user_agent":
+		if ua := c.Request.UserAgent(); ua != "" {
+			e.Str(name, ua)
+		}
+	case "referer":
+		if referer := c.Request.Referer(); referer != "" {
+			e.Str(name, referer)
+		}
+	case "request_bytes":
+		e.Int64(name, max(c.Request.ContentLength, 0))
+	case "response_bytes":
+		e.Int(name, max(c.Writer.Size(), 0))
+	case "route":
+		if route := c.FullPath(); route != "" {
+			e.Str(name, route)
+		}
+	case "request_id":
+		if id := c.GetString(RequestIDKey); id != "" {
+			e.Str(name, id)
+		}
+	case "user_id":
+		if userID := c.GetString("user_id"); userID != "" {
+			e.Str(name, userID)
+		}
+	case "trace_id":
+		if traceID := tracing.TraceIDFromContext(c.Request.Context()); traceID != "" {
+			e.Str(name, traceID)
+		}
+	case "upstream":
+		if target := c.GetString(upstream.TargetKey); target != "" {
+			e.Str(name, target)
+		}
+	case "error":
+		if msg := c.Errors.ByType(gin.ErrorTypePrivate).String(); msg != "" {
+			e.Str(name, msg)
+		}
+	}
+}
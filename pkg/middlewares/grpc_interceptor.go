@@ -7,8 +7,12 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/metrics"
+	"github.com/chunhui2001/zero4go/pkg/tracing"
 )
 
 // UnaryLoggingInterceptor Unary interceptor
@@ -23,7 +27,7 @@ func UnaryLoggingInterceptor(
 	// 调用真正的 RPC 方法
 	resp, err = handler(ctx, req)
 
-	Log.Infof("gRPC call: %s completed in %s",
+	Log.Ctx(ctx).Infof("gRPC call: %s completed in %s",
 		info.FullMethod, time.Since(start))
 
 	return resp, err
@@ -43,8 +47,150 @@ func StreamLoggingInterceptor(
 
 	err := handler(srv, ss)
 
-	Log.Infof("gRPC stream call: %s finished in %s",
+	Log.Ctx(ss.Context()).Infof("gRPC stream call: %s finished in %s",
 		info.FullMethod, time.Since(start))
 
 	return err
 }
+
+var (
+	grpcHandled  = metrics.NewCounter("grpc_server_handled_total", "gRPC 调用数", "method", "type", "code")
+	grpcDuration = metrics.NewHistogram("grpc_server_handling_seconds", "gRPC 调用耗时", nil, "method", "type")
+)
+
+func observeGrpc(method string, typ string, start time.Time, err error) {
+	grpcHandled.Inc(method, typ, status.Code(err).String())
+	grpcDuration.Observe(time.Since(start).Seconds(), method, typ)
+}
+
+// UnaryMetricsInterceptor 按方法和状态码记录 unary 调用数和耗时
+func UnaryMetricsInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	start := time.Now()
+
+	resp, err = handler(ctx, req)
+
+	observeGrpc(info.FullMethod, "unary", start, err)
+
+	return resp, err
+}
+
+// StreamMetricsInterceptor 按方法和状态码记录 stream 调用数和耗时
+func StreamMetricsInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	start := time.Now()
+
+	err := handler(srv, ss)
+
+	observeGrpc(info.FullMethod, "stream", start, err)
+
+	return err
+}
+
+// extractMetadata gRPC metadata 的键为小写, 与 traceparent 一致
+func extractMetadata(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+
+	if !ok {
+		return ctx
+	}
+
+	return tracing.Extract(ctx, func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+
+		return ""
+	})
+}
+
+func startServerSpan(ctx context.Context, method string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(extractMetadata(ctx), method, tracing.KindServer)
+
+	span.SetAttr("rpc.system", "grpc")
+	span.SetAttr("rpc.method", method)
+
+	return ctx, span
+}
+
+func endSpan(span *tracing.Span, err error) {
+	span.SetAttr("rpc.grpc.status_code", int(status.Code(err)))
+	span.SetError(err)
+	span.End()
+}
+
+// UnaryTracingInterceptor 读取 metadata 中的 traceparent, 为每次调用创建 server Span
+func UnaryTracingInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	ctx, span := startServerSpan(ctx, info.FullMethod)
+
+	resp, err = handler(ctx, req)
+
+	endSpan(span, err)
+
+	return resp, err
+}
+
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s wrappedStream) Context() context.Context {
+	return s.ctx
+}
+
+func StreamTracingInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	ctx, span := startServerSpan(ss.Context(), info.FullMethod)
+
+	err := handler(srv, wrappedStream{ServerStream: ss, ctx: ctx})
+
+	endSpan(span, err)
+
+	return err
+}
+
+// UnaryClientTracingInterceptor 调用其他 gRPC 服务时创建 client Span 并写入 traceparent:
+//
+//	grpc.NewClient(target, grpc.WithChainUnaryInterceptor(middlewares.UnaryClientTracingInterceptor))
+func UnaryClientTracingInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	ctx, span := tracing.Start(ctx, method, tracing.KindClient)
+
+	span.SetAttr("rpc.system", "grpc")
+	span.SetAttr("rpc.method", method)
+	span.SetAttr("server.address", cc.Target())
+
+	tracing.Inject(ctx, func(key string, value string) {
+		ctx = metadata.AppendToOutgoingContext(ctx, key, value)
+	})
+
+	err := invoker(ctx, method, req, reply, cc, opts...)
+
+	endSpan(span, err)
+
+	return err
+}
@@ -5,7 +5,10 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/gin-gonic/gin"
+
 	"github.com/chunhui2001/zero4go/pkg/config"
+	"github.com/chunhui2001/zero4go/pkg/ratelimit"
 	"github.com/chunhui2001/zero4go/pkg/utils"
 )
 
@@ -29,6 +32,21 @@ func Init() {
 			}
 		}
 	}
+
+	ratelimit.AccessKeyValidator = ValidAccessKey
+}
+
+// ValidAccessKey access key 已配置在 Access-Clients 中、已启用且请求签名正确
+func ValidAccessKey(c *gin.Context, accessKeyId string) bool {
+	accessClient := accessClientsMap[accessKeyId]
+
+	if accessClient == nil || !accessClient.Enabled {
+		return false
+	}
+
+	ok, err := CheckSign(accessKeyId, accessClient.SecretAccessKey, c.Request.Method, RequestURL(c.Request))
+
+	return ok && err == nil
 }
 
 func RequestURL(req *http.Request) *url.URL {
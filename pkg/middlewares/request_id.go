@@ -0,0 +1,122 @@
+package middlewares
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+)
+
+// RequestIDKey gin.Context 中请求 ID 的键
+const RequestIDKey = "request_id"
+
+// grpcRequestIDKey gRPC metadata 的键为小写
+var grpcRequestIDKey = strings.ToLower(RequestIDHeader)
+
+// validRequestID 只接受长度不超过 128 的可打印 ASCII, 避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+func requestIDOrNew(id string) string {
+	if validRequestID(id) {
+		return id
+	}
+
+	return uuid.NewString()
+}
+
+// RequestID 沿用请求头中的 X-Request-ID, 没有时生成; 写入响应头和请求的 context, 之后 Log.Ctx(c.Request.Context()) 输出请求 ID、用户 ID 和路由
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := requestIDOrNew(c.GetHeader(RequestIDHeader))
+
+		c.Header(RequestIDHeader, id)
+		c.Set(RequestIDKey, id)
+
+		fields := &Fields{RequestID: id, Route: c.FullPath()}
+
+		// 认证中间件在其后才设置 user_id (RequestContext.UserID)
+		fields.SetUserLookup(func() string {
+			return c.GetString("user_id")
+		})
+
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), fields))
+
+		c.Next()
+
+		// 请求结束后 gin.Context 会被复用, 不能再读取
+		fields.SetUserID(c.GetString("user_id"))
+	}
+}
+
+func incomingRequestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(grpcRequestIDKey); len(values) > 0 {
+			return values[0]
+		}
+	}
+
+	return ""
+}
+
+func withRequestID(ctx context.Context, method string) context.Context {
+	id := requestIDOrNew(incomingRequestID(ctx))
+
+	// 写入响应 header, 失败时 (如已发送 header) 忽略
+	_ = grpc.SetHeader(ctx, metadata.Pairs(grpcRequestIDKey, id))
+
+	return NewContext(ctx, &Fields{RequestID: id, Route: method})
+}
+
+// UnaryRequestIDInterceptor 沿用 metadata 中的 x-request-id, 没有时生成, 并写入响应 header
+func UnaryRequestIDInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	return handler(withRequestID(ctx, info.FullMethod), req)
+}
+
+func StreamRequestIDInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	return handler(srv, wrappedStream{ServerStream: ss, ctx: withRequestID(ss.Context(), info.FullMethod)})
+}
+
+// UnaryClientRequestIDInterceptor 调用其他 gRPC 服务时传递当前的请求 ID:
+//
+//	grpc.NewClient(target, grpc.WithChainUnaryInterceptor(middlewares.UnaryClientRequestIDInterceptor))
+func UnaryClientRequestIDInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption,
+) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, grpcRequestIDKey, id)
+	}
+
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
@@ -0,0 +1,344 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/chunhui2001/zero4go/pkg/gredis"
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+)
+
+const (
+	SlidingWindow = "sliding_window"
+	TokenBucket   = "token_bucket"
+)
+
+// 限流维度
+const (
+	KeyIP        = "ip"         // 客户端 IP
+	KeyAccessKey = "access_key" // Access-Clients 的 AWSAccessKeyId, 请求未携带或校验失败时按 IP
+	KeyUser      = "user"       // RequestContext.UserID(), 未登录时按 IP
+)
+
+// 请求已经过限流计数, 避免全局和路由组都注册了中间件时重复计数
+const handledKey = "ratelimit.handled"
+
+// AccessKeyField 请求中 access key 的参数名, 与 middlewares.AWSAccessKeyIdFieldKey 相同
+const AccessKeyField = "AWSAccessKeyId"
+
+// AccessKeyValidator 校验请求携带的 access key: 已配置在 Access-Clients 中、已启用且签名正确, 由 middlewares.Init 设置;
+// 未设置或校验失败时按 IP 限流, 避免伪造的 access key 绕过限流或使用其他客户端的配额 (Overrides)
+var AccessKeyValidator func(c *gin.Context, accessKey string) bool
+
+type RateLimitConf struct {
+	Enable bool `mapstructure:"RATE_LIMIT_ENABLE"`
+	// Store 计数存放位置: redis (多节点共享) 或 memory; redis 未启用时回退到 memory
+	Store     string `mapstructure:"RATE_LIMIT_STORE"`
+	Algorithm string `mapstructure:"RATE_LIMIT_ALGORITHM"` // sliding_window / token_bucket
+	// 默认规则: 未匹配 RateLimit-Rules 的请求按 Key 维度, 每 Window 最多 Limit 次, Limit <= 0 表示不限制
+	Key    string        `mapstructure:"RATE_LIMIT_KEY"`
+	Limit  int           `mapstructure:"RATE_LIMIT_LIMIT"`
+	Window time.Duration `mapstructure:"RATE_LIMIT_WINDOW"`
+	// Burst 令牌桶容量, <= 0 时等于 Limit
+	Burst int `mapstructure:"RATE_LIMIT_BURST"`
+	// SkipPaths 逗号分隔的路径前缀, 不做限流
+	SkipPaths string `mapstructure:"RATE_LIMIT_SKIP_PATHS"`
+	// RedisPrefix redis key 前缀, 多个服务共用一个 redis 时用于区分
+	RedisPrefix string `mapstructure:"RATE_LIMIT_REDIS_PREFIX"`
+	// LocalSize memory 模式下最多保留的计数器个数
+	LocalSize int `mapstructure:"RATE_LIMIT_LOCAL_SIZE"`
+}
+
+var Settings = &RateLimitConf{
+	Enable:      true,
+	Store:       "redis",
+	Algorithm:   SlidingWindow,
+	Key:         KeyIP,
+	Limit:       10,
+	Window:      time.Second,
+	SkipPaths:   "/favicon.ico,/health,/metrics",
+	RedisPrefix: "ratelimit:",
+	LocalSize:   100000,
+}
+
+// Rule 按路由前缀配置的限流规则, 由 application.yml 的 RateLimit-Rules 加载, 最长前缀优先;
+// Key 为 user 时, 限流中间件需注册在设置 user_id 的认证中间件之后 (如 group.Use(auth, ratelimit.Middleware())),
+// 一个请求只计数一次, 先执行的限流中间件生效
+type Rule struct {
+	Name      string
+	Path      string // 路由前缀, 如 /api/v1
+	Methods   []string
+	Key       string // ip / access_key / user
+	Limit     int    // <= 0 表示该前缀不限流
+	Window    time.Duration
+	Algorithm string
+	Burst     int
+	// Overrides 按限流维度的值单独设置 Limit, 如 access key 23e3 → 1000, 用于给重要客户端更高配额
+	Overrides map[string]int
+}
+
+var Rules []Rule
+
+var limiter *Limiter
+
+func Init() {
+	if !Settings.Enable {
+		Log.Infof("RateLimit-Disabled: val=%t", Settings.Enable)
+
+		return
+	}
+
+	var store Store
+
+	if Settings.Store == "redis" && gredis.RedisClient != nil {
+		store = NewRedisStore(gredis.RedisClient, Settings.RedisPrefix)
+	} else {
+		if Settings.Store == "redis" {
+			Log.Warnf("RateLimit-Redis-Unavailable: fallback to memory store, limits are per node")
+		}
+
+		store = NewMemoryStore(Settings.LocalSize)
+	}
+
+	l, err := New(store, *Settings, Rules)
+
+	if err != nil {
+		Log.Errorf("RateLimit-Init-Failed: Error=%s", err.Error())
+
+		return
+	}
+
+	limiter = l
+
+	Log.Infof("RateLimit-Initialized: Store=%s, Algorithm=%s, Key=%s, Limit=%d, Window=%s, Rules=%d", store.Name(), Settings.Algorithm, Settings.Key, Settings.Limit, Settings.Window, len(Rules))
+}
+
+// Middleware 使用 Init 创建的限流器, 未启用时直接放行
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+
+			return
+		}
+
+		limiter.Handle(c)
+	}
+}
+
+type Limiter struct {
+	store     Store
+	rules     []Rule // 按 Path 长度降序, 最后一条为默认规则
+	skipPaths []string
+}
+
+// New 创建限流器; conf 中的默认规则放在 rules 之后, 匹配所有路径
+func New(store Store, conf RateLimitConf, rules []Rule) (*Limiter, error) {
+	var l = &Limiter{store: store}
+
+	for _, p := range strings.Split(conf.SkipPaths, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			l.skipPaths = append(l.skipPaths, p)
+		}
+	}
+
+	for _, r := range rules {
+		r = withDefaults(r, conf)
+
+		if err := validate(r); err != nil {
+			return nil, err
+		}
+
+		l.rules = append(l.rules, r)
+	}
+
+	sort.SliceStable(l.rules, func(i, j int) bool {
+		return len(l.rules[i].Path) > len(l.rules[j].Path)
+	})
+
+	def := withDefaults(Rule{Name: "default", Path: "/", Limit: conf.Limit, Burst: conf.Burst}, conf)
+
+	if err := validate(def); err != nil {
+		return nil, err
+	}
+
+	l.rules = append(l.rules, def)
+
+	return l, nil
+}
+
+func withDefaults(r Rule, conf RateLimitConf) Rule {
+	if r.Key == "" {
+		r.Key = conf.Key
+	}
+
+	if r.Window <= 0 {
+		r.Window = conf.Window
+	}
+
+	if r.Algorithm == "" {
+		r.Algorithm = conf.Algorithm
+	}
+
+	if r.Name == "" {
+		r.Name = r.Path
+	}
+
+	return r
+}
+
+func validate(r Rule) error {
+	if r.Algorithm != SlidingWindow && r.Algorithm != TokenBucket {
+		return fmt.Errorf("ratelimit: rule %s: unknown algorithm %q", r.Name, r.Algorithm)
+	}
+
+	if r.Key != KeyIP && r.Key != KeyAccessKey && r.Key != KeyUser {
+		return fmt.Errorf("ratelimit: rule %s: unknown key %q", r.Name, r.Key)
+	}
+
+	// 计数以毫秒为单位
+	if r.Limit > 0 && r.Window < time.Millisecond {
+		return fmt.Errorf("ratelimit: rule %s: window must be at least 1ms, got %s", r.Name, r.Window)
+	}
+
+	return nil
+}
+
+// match 返回第一条 (最长前缀) 匹配的规则
+func (l *Limiter) match(method string, path string) *Rule {
+	for _, p := range l.skipPaths {
+		if strings.HasPrefix(path, p) {
+			return nil
+		}
+	}
+
+	for i := range l.rules {
+		r := &l.rules[i]
+
+		if !strings.HasPrefix(path, r.Path) {
+			continue
+		}
+
+		if len(r.Methods) > 0 && !containsFold(r.Methods, method) {
+			continue
+		}
+
+		return r
+	}
+
+	return nil
+}
+
+func containsFold(items []string, s string) bool {
+	for _, item := range items {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keyOf 限流维度的值, 返回的 dim 为实际使用的维度 (未携带有效的 access key 或未登录时为 ip)
+func keyOf(c *gin.Context, key string) (dim string, val string) {
+	switch key {
+	case KeyAccessKey:
+		if v := c.Query(AccessKeyField); v != "" && AccessKeyValidator != nil && AccessKeyValidator(c, v) {
+			return KeyAccessKey, v
+		}
+	case KeyUser:
+		if v := c.GetString("user_id"); v != "" {
+			return KeyUser, v
+		}
+	}
+
+	return KeyIP, c.ClientIP()
+}
+
+// Handle 按匹配的规则计数, 写入 RateLimit-* 响应头, 超出时返回 429 和 Retry-After;
+// 存储不可用时放行 (fail open), 只记录日志
+func (l *Limiter) Handle(c *gin.Context) {
+	if c.GetBool(handledKey) {
+		c.Next()
+
+		return
+	}
+
+	r := l.match(c.Request.Method, c.Request.URL.Path)
+
+	if r == nil {
+		c.Next()
+
+		return
+	}
+
+	c.Set(handledKey, true)
+
+	dim, val := keyOf(c, r.Key)
+	limit := r.Limit
+
+	// 只有校验过的 access key 和登录用户才使用单独的配额
+	if dim != KeyIP {
+		if v, ok := r.Overrides[val]; ok {
+			limit = v
+		}
+	}
+
+	if limit <= 0 {
+		c.Next()
+
+		return
+	}
+
+	burst := r.Burst
+
+	if burst <= 0 || r.Algorithm != TokenBucket {
+		burst = limit
+	}
+
+	res, err := l.store.Take(c.Request.Context(), r.Name+":"+dim+":"+val, Quota{Algorithm: r.Algorithm, Limit: limit, Window: r.Window, Burst: burst})
+
+	if err != nil {
+		Log.Warnf("RateLimit-Store-Error: Rule=%s, Key=%s:%s, Error=%s", r.Name, dim, val, err.Error())
+
+		c.Next()
+
+		return
+	}
+
+	h := c.Writer.Header()
+
+	h.Set("RateLimit-Limit", strconv.Itoa(limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit, ceilSeconds(r.Window)))
+
+	if !res.Allowed {
+		retryAfter := ceilSeconds(res.RetryAfter)
+
+		h.Set("Retry-After", strconv.Itoa(retryAfter))
+
+		Log.Debugf("RateLimit-Rejected: Rule=%s, Key=%s:%s, Limit=%d, Window=%s, RetryAfter=%ds", r.Name, dim, val, limit, r.Window, retryAfter)
+
+		c.String(429, "Too many requests. Try again in "+res.RetryAfter.Round(time.Millisecond).String())
+		c.Abort()
+
+		return
+	}
+
+	c.Next()
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+
+	return int(math.Ceil(d.Seconds()))
+}
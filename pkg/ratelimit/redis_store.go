@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 与 slidingWindow 相同的算法, 使用 redis 的时间, 避免各节点时钟不一致 (redis 5 以下需 replicate_commands 才能在 TIME 之后写入);
+// KEYS[1]: 限流键, ARGV: limit, window(ms); 返回 {allowed, remaining, reset(ms), retry(ms)}
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local start = now - now % window
+
+local data = redis.call('HMGET', KEYS[1], 'start', 'cur', 'prev')
+local s = tonumber(data[1]) or start
+local cur = tonumber(data[2]) or 0
+local prev = tonumber(data[3]) or 0
+
+if s ~= start then
+	if s == start - window then prev = cur else prev = 0 end
+	cur = 0
+end
+
+local elapsed = now - start
+local count = prev * (window - elapsed) / window + cur
+local reset = window - elapsed
+
+if count + 1 > limit then
+	local retry = window - elapsed
+	if cur + 1 <= limit and prev > 0 then
+		retry = math.ceil(window * (1 - (limit - cur - 1) / prev)) - elapsed
+	end
+	if retry < 1 then retry = 1 end
+	redis.call('HSET', KEYS[1], 'start', start, 'cur', cur, 'prev', prev)
+	redis.call('PEXPIRE', KEYS[1], window * 2)
+	return {0, 0, reset, retry}
+end
+
+redis.call('HSET', KEYS[1], 'start', start, 'cur', cur + 1, 'prev', prev)
+redis.call('PEXPIRE', KEYS[1], window * 2)
+
+return {1, math.floor(limit - count - 1), reset, 0}
+`)
+
+// 与 tokenBucket 相同的算法; KEYS[1]: 限流键, ARGV: limit, window(ms), burst
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1]) / tonumber(ARGV[2])
+local capacity = tonumber(ARGV[3])
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or capacity
+local ts = tonumber(data[2]) or now
+
+tokens = math.min(capacity, tokens + (now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate) + 1000)
+
+return {allowed, math.floor(tokens), math.ceil((capacity - tokens) / rate), retry}
+`)
+
+// RedisStore 多节点共享计数, 每次计数是一次 Lua 脚本调用 (EVALSHA), 保证原子性
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (r *RedisStore) Name() string {
+	return "redis"
+}
+
+func (r *RedisStore) Take(ctx context.Context, key string, q Quota) (Result, error) {
+	var vals []int64
+	var err error
+
+	if q.Algorithm == TokenBucket {
+		vals, err = tokenBucketScript.Run(ctx, r.client, []string{r.prefix + key}, q.Limit, q.Window.Milliseconds(), q.Burst).Int64Slice()
+	} else {
+		vals, err = slidingWindowScript.Run(ctx, r.client, []string{r.prefix + key}, q.Limit, q.Window.Milliseconds()).Int64Slice()
+	}
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Allowed:    vals[0] == 1,
+		Remaining:  int(vals[1]),
+		Reset:      time.Duration(vals[2]) * time.Millisecond,
+		RetryAfter: time.Duration(vals[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// Quota 一条规则对某个限流键的配额
+type Quota struct {
+	Algorithm string
+	Limit     int // 每个 Window 允许的请求数
+	Window    time.Duration
+	Burst     int // 令牌桶容量
+}
+
+// Result 一次计数的结果
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // sliding_window: 当前窗口结束; token_bucket: 令牌桶补满
+	RetryAfter time.Duration // 被拒绝时, 最早可以重试的时间
+}
+
+// Store 计数存储, 内置 memory (单节点) 和 redis (多节点共享)
+type Store interface {
+	Name() string
+	Take(ctx context.Context, key string, q Quota) (Result, error)
+}
+
+// state 一个限流键的计数状态, memory 与 redis 使用相同的算法
+type state struct {
+	start int64   // sliding_window: 当前窗口开始时间 (ms)
+	cur   float64 // sliding_window: 当前窗口计数; token_bucket: 剩余令牌
+	prev  float64 // sliding_window: 上一个窗口计数
+	ts    int64   // token_bucket: 上次补充令牌的时间 (ms)
+}
+
+// slidingWindow 滑动窗口计数: 上一个窗口的计数按未过去的比例加权, 加上当前窗口的计数
+func slidingWindow(s *state, now int64, q Quota) Result {
+	window := q.Window.Milliseconds()
+	start := now - now%window
+
+	if s.start != start {
+		if s.start == start-window {
+			s.prev = s.cur
+		} else {
+			s.prev = 0
+		}
+
+		s.cur = 0
+		s.start = start
+	}
+
+	elapsed := now - start
+	limit := float64(q.Limit)
+	count := s.prev*float64(window-elapsed)/float64(window) + s.cur
+	reset := time.Duration(window-elapsed) * time.Millisecond
+
+	if count+1 > limit {
+		// 当前窗口已满时等到下一个窗口, 否则等到上一个窗口的加权计数降到足够低
+		retry := window - elapsed
+
+		if s.cur+1 <= limit && s.prev > 0 {
+			retry = int64(math.Ceil(float64(window)*(1-(limit-s.cur-1)/s.prev))) - elapsed
+		}
+
+		return Result{Allowed: false, Remaining: 0, Reset: reset, RetryAfter: time.Duration(max(retry, 1)) * time.Millisecond}
+	}
+
+	s.cur++
+
+	return Result{Allowed: true, Remaining: int(limit - count - 1), Reset: reset}
+}
+
+// tokenBucket 令牌桶: 每 Window 补充 Limit 个令牌, 最多 Burst 个
+func tokenBucket(s *state, now int64, q Quota) Result {
+	rate := float64(q.Limit) / float64(q.Window.Milliseconds()) // 每毫秒补充的令牌
+	capacity := float64(q.Burst)
+
+	if s.ts == 0 {
+		s.cur = capacity
+		s.ts = now
+	}
+
+	s.cur = math.Min(capacity, s.cur+float64(now-s.ts)*rate)
+	s.ts = now
+
+	if s.cur < 1 {
+		retry := int64(math.Ceil((1 - s.cur) / rate))
+
+		return Result{Allowed: false, Remaining: 0, Reset: msOf((capacity - s.cur) / rate), RetryAfter: time.Duration(retry) * time.Millisecond}
+	}
+
+	s.cur--
+
+	return Result{Allowed: true, Remaining: int(s.cur), Reset: msOf((capacity - s.cur) / rate)}
+}
+
+func msOf(ms float64) time.Duration {
+	return time.Duration(math.Ceil(ms)) * time.Millisecond
+}
+
+// MemoryStore 进程内计数, 多节点部署时每个节点单独计数
+type MemoryStore struct {
+	mu     sync.Mutex
+	states *lru.Cache[string, *state]
+}
+
+// NewMemoryStore size 为最多保留的限流键个数, 超出时淘汰最久未使用的键
+func NewMemoryStore(size int) *MemoryStore {
+	if size <= 0 {
+		size = 100000
+	}
+
+	states, _ := lru.New[string, *state](size)
+
+	return &MemoryStore{states: states}
+}
+
+func (m *MemoryStore) Name() string {
+	return "memory"
+}
+
+func (m *MemoryStore) Take(_ context.Context, key string, q Quota) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.states.Get(key)
+
+	if !ok {
+		s = &state{}
+		m.states.Add(key, s)
+	}
+
+	now := time.Now().UnixMilli()
+
+	if q.Algorithm == TokenBucket {
+		return tokenBucket(s, now, q), nil
+	}
+
+	return slidingWindow(s, now, q), nil
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/elastic/go-elasticsearch/v9/esapi"
 	"github.com/elastic/go-elasticsearch/v9/esutil"
 
+	"github.com/chunhui2001/zero4go/pkg/health"
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
 	"github.com/chunhui2001/zero4go/pkg/utils"
 )
@@ -75,6 +76,25 @@ func Init() {
 	}
 
 	Client.Ping()
+
+	health.Register("elasticsearch", Client.HealthCheck)
+}
+
+// HealthCheck 供 /health/ready 使用, 集群不可达或返回错误状态时返回 error
+func (c *EsClient) HealthCheck(ctx context.Context) error {
+	res, err := c.Client.Ping(c.Client.Ping.WithContext(ctx))
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("ping: %s", res.Status())
+	}
+
+	return nil
 }
 
 func (c *EsClient) Ping() {
@@ -16,6 +16,7 @@ import (
 	"github.com/elastic/go-elasticsearch/v9/esutil"
 	"github.com/opensearch-project/opensearch-go/v4"
 
+	"github.com/chunhui2001/zero4go/pkg/health"
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
 	"github.com/chunhui2001/zero4go/pkg/utils"
 )
@@ -75,6 +76,31 @@ func Init() {
 	}
 
 	Client.Ping()
+
+	health.Register("opensearch", Client.HealthCheck)
+}
+
+// HealthCheck 供 /health/ready 使用, 集群不可达或返回错误状态时返回 error
+func (c *EsClient) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := c.Client.Perform(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("GET /: %s", res.Status)
+	}
+
+	return nil
 }
 
 func (c *EsClient) Ping() {
@@ -0,0 +1,310 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gin-contrib/gzip"
+	"github.com/gin-contrib/static"
+	"github.com/gin-gonic/gin"
+
+	"github.com/chunhui2001/zero4go/pkg/config"
+	"github.com/chunhui2001/zero4go/pkg/favicon"
+	"github.com/chunhui2001/zero4go/pkg/gsql"
+	"github.com/chunhui2001/zero4go/pkg/health"
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/metrics"
+	"github.com/chunhui2001/zero4go/pkg/middlewares"
+	"github.com/chunhui2001/zero4go/pkg/ratelimit"
+	"github.com/chunhui2001/zero4go/pkg/tracing"
+	"github.com/chunhui2001/zero4go/pkg/utils"
+)
+
+// 内置中间件, 默认按此顺序安装 (SERVER_MIDDLEWARES)
+const (
+	MiddlewareRecovery  = "recovery"
+	MiddlewareRequestID = "request_id"
+	MiddlewareTracing   = "tracing"
+	MiddlewareMetrics   = "metrics"
+	MiddlewareRateLimit = "ratelimit"
+	MiddlewareGzip      = "gzip"
+	MiddlewareStatic    = "static"
+	MiddlewareFavicon   = "favicon"
+	MiddlewareAccessLog = "access_log"
+)
+
+// 默认路由 (SERVER_ROUTES)
+const (
+	RouteInfo      = "info"       // GET /info
+	RouteGsqlStats = "gsql_stats" // GET /metrics/gsql
+	RouteHealth    = "health"     // GET /health/live, /health/ready, /health/ready/:name
+	RouteMetrics   = "metrics"    // GET /metrics
+)
+
+// Option 调整 Setup 安装的中间件和默认路由, 在配置 (SERVER_*) 之后生效
+//
+//	Setup(func(r *Application) { ... },
+//		WithoutMiddlewares(MiddlewareGzip, MiddlewareStatic),
+//		WithMiddleware("cors", cors.Default()),
+//		WithoutRoutes(RouteInfo),
+//	)
+type Option func(*pipeline)
+
+type pipeline struct {
+	middlewares []string
+	custom      map[string]gin.HandlerFunc
+	routes      []string
+
+	gzipLevel    int
+	gzipExcluded []string
+
+	staticPrefix string
+	staticRoot   string
+
+	accessLog      config.AccessLogConf
+	trustedProxies []string
+
+	upstreams []config.UpstreamConf
+}
+
+func newPipeline(conf *config.ServerConf) *pipeline {
+	return &pipeline{
+		middlewares:    splitList(conf.Middlewares),
+		custom:         make(map[string]gin.HandlerFunc),
+		routes:         splitList(conf.Routes),
+		gzipLevel:      conf.GzipLevel,
+		gzipExcluded:   splitList(conf.GzipExcludedExtensions),
+		staticPrefix:   conf.StaticPrefix,
+		staticRoot:     conf.StaticRoot,
+		accessLog:      conf.AccessLog,
+		trustedProxies: splitList(conf.TrustedProxies),
+		upstreams:      slices.Clone(conf.Upstreams),
+	}
+}
+
+func splitList(s string) []string {
+	var out []string
+
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+
+	return out
+}
+
+// WithMiddlewares 按 names 的顺序安装中间件, 替换配置中的列表; names 可以是内置中间件或 WithMiddleware 注册的名称
+func WithMiddlewares(names ...string) Option {
+	return func(p *pipeline) {
+		p.middlewares = slices.Clone(names)
+	}
+}
+
+// WithoutMiddlewares 不安装 names 中的中间件
+func WithoutMiddlewares(names ...string) Option {
+	return func(p *pipeline) {
+		p.middlewares = slices.DeleteFunc(p.middlewares, func(name string) bool {
+			return slices.Contains(names, name)
+		})
+	}
+}
+
+// WithMiddleware 注册名为 name 的中间件: 与内置中间件同名时替换之 (位置不变), 否则追加到最后
+func WithMiddleware(name string, h gin.HandlerFunc) Option {
+	return func(p *pipeline) {
+		p.custom[name] = h
+
+		if !slices.Contains(p.middlewares, name) {
+			p.middlewares = append(p.middlewares, name)
+		}
+	}
+}
+
+// WithRoutes 只注册 names 中的默认路由, 替换配置中的列表
+func WithRoutes(names ...string) Option {
+	return func(p *pipeline) {
+		p.routes = slices.Clone(names)
+	}
+}
+
+// WithoutRoutes 不注册 names 中的默认路由
+func WithoutRoutes(names ...string) Option {
+	return func(p *pipeline) {
+		p.routes = slices.DeleteFunc(p.routes, func(name string) bool {
+			return slices.Contains(names, name)
+		})
+	}
+}
+
+// WithGzip 设置压缩级别和不压缩的扩展名
+func WithGzip(level int, excludedExtensions ...string) Option {
+	return func(p *pipeline) {
+		p.gzipLevel = level
+		p.gzipExcluded = excludedExtensions
+	}
+}
+
+// WithStatic 把 root 目录映射到 prefix 路径下
+func WithStatic(prefix string, root string) Option {
+	return func(p *pipeline) {
+		p.staticPrefix = prefix
+		p.staticRoot = root
+	}
+}
+
+// WithAccessLogSkips 不记录访问日志的路径
+func WithAccessLogSkips(paths ...string) Option {
+	return func(p *pipeline) {
+		p.accessLog.SkipPaths = strings.Join(paths, ",")
+	}
+}
+
+// WithAccessLog 替换访问日志的配置 (SERVER_ACCESS_LOG_*)
+func WithAccessLog(conf config.AccessLogConf) Option {
+	return func(p *pipeline) {
+		p.accessLog = conf
+	}
+}
+
+// WithTrustedProxies 信任其 X-Forwarded-For / X-Real-IP 的代理 (IP 或 CIDR), 为空时不信任任何代理
+func WithTrustedProxies(proxies ...string) Option {
+	return func(p *pipeline) {
+		p.trustedProxies = proxies
+	}
+}
+
+// WithUpstream 追加一条反向代理路由, 同 Application.Upstream
+func WithUpstream(from string, to string, remotes ...string) Option {
+	return func(p *pipeline) {
+		p.upstreams = append(p.upstreams, config.UpstreamConf{From: from, To: to, Remotes: remotes})
+	}
+}
+
+// handler 内置或自定义的中间件, 名称未知时返回 nil
+func (p *pipeline) handler(name string) gin.HandlerFunc {
+	if h, ok := p.custom[name]; ok {
+		return h
+	}
+
+	switch name {
+	case MiddlewareRecovery:
+		return gin.Recovery()
+	case MiddlewareRequestID:
+		return middlewares.RequestID()
+	case MiddlewareTracing:
+		return tracing.Middleware()
+	case MiddlewareMetrics:
+		return metrics.Middleware()
+	case MiddlewareRateLimit:
+		return ratelimit.Middleware()
+	case MiddlewareGzip:
+		return gzip.Gzip(p.gzipLevel, gzip.WithExcludedExtensions(p.gzipExcluded))
+	case MiddlewareStatic:
+		root := p.staticRoot
+
+		if !filepath.IsAbs(root) {
+			root = filepath.Join(utils.RootDir(), root)
+		}
+
+		return static.Serve(p.staticPrefix, static.LocalFile(root, false))
+	case MiddlewareFavicon:
+		return favicon.Favicon()
+	case MiddlewareAccessLog:
+		return middlewares.NewAccessLog(p.accessLog)
+	}
+
+	return nil
+}
+
+func (p *pipeline) install(r *Application) {
+	// c.ClientIP() 只采用受信任代理传入的地址, 影响访问日志、限流和 tracing
+	if err := r.SetTrustedProxies(p.trustedProxies); err != nil {
+		Log.Errorf("TrustedProxies-Invalid: Proxies=%s, Error=%s", strings.Join(p.trustedProxies, ","), err.Error())
+	}
+
+	var installed []string
+
+	for _, name := range p.middlewares {
+		h := p.handler(name)
+
+		if h == nil {
+			Log.Warnf("Middleware-Unknown: Name=%s", name)
+
+			continue
+		}
+
+		r.Use(h)
+
+		installed = append(installed, name)
+	}
+
+	Log.Infoe1().Msgf("USE, Middlewares=%s", strings.Join(installed, ","))
+}
+
+func (p *pipeline) registerRoutes(r *Application) {
+	for _, name := range p.routes {
+		switch name {
+		case RouteInfo:
+			r.GET("/info", func(c *RequestContext) {
+				c.Text("Yeah, your server is running.")
+			})
+		case RouteGsqlStats:
+			// 各数据源连接池状态
+			r.GET("/metrics/gsql", func(c *RequestContext) {
+				c.OK(gsql.Stats())
+			})
+		case RouteHealth:
+			// 存活探针只表示进程可响应; 就绪探针检查各依赖, DOWN 时返回 503
+			r.GET("/health/live", func(c *RequestContext) {
+				c.JSON(http.StatusOK, health.Live())
+			})
+			r.GET("/health/ready", func(c *RequestContext) {
+				report := health.Ready(c.Request.Context())
+
+				c.JSON(statusCodeOf(report.Status), report)
+			})
+			r.GET("/health/ready/:name", func(c *RequestContext) {
+				comp := health.Check(c.Request.Context(), c.Param("name"))
+
+				if comp == nil {
+					c.JSON(http.StatusNotFound, gin.H{"status": "UNKNOWN", "names": health.Names()})
+
+					return
+				}
+
+				c.JSON(statusCodeOf(comp.Status), comp)
+			})
+		case RouteMetrics:
+			if !metrics.Settings.Enable {
+				continue
+			}
+
+			// Prometheus 文本格式
+			r.GET("/metrics", func(c *RequestContext) {
+				var b bytes.Buffer
+
+				metrics.Write(&b)
+
+				c.Data(http.StatusOK, metrics.ContentType, b.Bytes())
+			})
+		default:
+			Log.Warnf("Route-Unknown: Name=%s", name)
+		}
+	}
+
+	for _, u := range p.upstreams {
+		r.Upstream(u.From, u.To, u.Remotes...)
+	}
+}
+
+func statusCodeOf(status string) int {
+	if status == health.StatusUp {
+		return http.StatusOK
+	}
+
+	return http.StatusServiceUnavailable
+}
@@ -6,6 +6,7 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/chunhui2001/zero4go/pkg/middlewares"
 	"github.com/chunhui2001/zero4go/pkg/utils"
 	"github.com/gin-gonic/gin"
 )
@@ -18,6 +19,11 @@ func (c *RequestContext) UserID() string {
 	return c.GetString("user_id")
 }
 
+// RequestID 由 middlewares.RequestID 设置
+func (c *RequestContext) RequestID() string {
+	return c.GetString(middlewares.RequestIDKey)
+}
+
 func (c *RequestContext) OK(data any) {
 	c.JSON(200, gin.H{"code": 200, "data": data})
 }
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"net"
@@ -17,16 +18,14 @@ import (
 	"github.com/foolin/goview"
 	"github.com/foolin/goview/supports/ginview"
 
-	"github.com/gin-contrib/gzip"
-	"github.com/gin-contrib/static"
 	"github.com/gin-gonic/gin"
 	"github.com/vektah/gqlparser/v2/ast"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
-	ratelimit "github.com/JGLTechnologies/gin-rate-limit"
 	"github.com/chunhui2001/zero4go/pkg/config"
-	"github.com/chunhui2001/zero4go/pkg/favicon"
+	"github.com/chunhui2001/zero4go/pkg/health"
 	"github.com/chunhui2001/zero4go/pkg/middlewares"
 	"github.com/chunhui2001/zero4go/pkg/utils"
 
@@ -70,13 +69,16 @@ func playgroundHandler(endpoint string) gin.HandlerFunc {
 	}
 }
 
-func errorHandler(c *gin.Context, info ratelimit.Info) {
-	c.String(429, "Too many requests. Try again in "+time.Until(info.ResetTime).String())
-}
-
-func Setup(f func(*Application)) *Application {
+// Setup 创建 Application: 按配置 (SERVER_*) 和 opts 安装中间件、注册默认路由, 然后由 f 注册业务路由
+func Setup(f func(*Application), opts ...Option) *Application {
 	gin.SetMode(gin.ReleaseMode)
 
+	p := newPipeline(config.ServerSetting)
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
 	r := &Application{Engine: gin.New()}
 
 	r.HTMLRender = ginview.New(goview.Config{
@@ -92,26 +94,7 @@ func Setup(f func(*Application)) *Application {
 		DisableCache: true,
 	})
 
-	rateLimitMiddleWare := ratelimit.RateLimiter(
-		ratelimit.InMemoryStore(&ratelimit.InMemoryOptions{
-			Rate:  time.Second,
-			Limit: 10,
-		}),
-		&ratelimit.Options{
-			ErrorHandler: errorHandler,
-			KeyFunc: func(c *gin.Context) string {
-				return c.ClientIP()
-			},
-		})
-
-	r.Use(gin.Recovery())
-	r.Use(rateLimitMiddleWare)
-
-	r.Use(gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedExtensions([]string{".pdf", ".mp4", ".ico"})))
-	r.Use(static.Serve("/RichMedias", static.LocalFile(filepath.Join(utils.RootDir(), "./static"), false)))
-	r.Use(favicon.Favicon())
-
-	r.Use(middlewares.AccessLog("/favicon.ico", "/static"))
+	p.install(r)
 
 	if config.AppSetting.GraphQLEnable {
 		r.POST(config.AppSetting.GraphQLServerURI, graphqlHandler())
@@ -119,11 +102,7 @@ func Setup(f func(*Application)) *Application {
 	}
 
 	// routers
-	r.GET("/info", func(c *RequestContext) {
-		c.Text("Yeah, your server is running.")
-	})
-
-	r.Upstream("/index2", "/index", "http://127.0.0.1:8080")
+	p.registerRoutes(r)
 
 	// customer http router
 	f(r)
@@ -142,12 +121,15 @@ func Setup(f func(*Application)) *Application {
 func (a *Application) Run(f func(*grpc.Server)) {
 
 	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(middlewares.UnaryLoggingInterceptor),
-		grpc.StreamInterceptor(middlewares.StreamLoggingInterceptor),
+		grpc.ChainUnaryInterceptor(middlewares.UnaryRequestIDInterceptor, middlewares.UnaryTracingInterceptor, middlewares.UnaryMetricsInterceptor, middlewares.UnaryLoggingInterceptor),
+		grpc.ChainStreamInterceptor(middlewares.StreamRequestIDInterceptor, middlewares.StreamTracingInterceptor, middlewares.StreamMetricsInterceptor, middlewares.StreamLoggingInterceptor),
 	)
 
 	pb.RegisterGreeterServer(grpcServer, &rpc.GreeterServer{})
 
+	// 标准 gRPC health 服务, 与 /health/ready 使用相同的检查
+	healthpb.RegisterHealthServer(grpcServer, health.NewGRPCServer())
+
 	// customer grpc service
 	f(grpcServer)
 
@@ -175,15 +157,30 @@ func (a *Application) Run(f func(*grpc.Server)) {
 		Log.Fatal(err.Error())
 	}
 
-	AddShutDownHook(func() {
-		Log.Info("shutting down server")
+	// 先关闭 HTTP, 再等待 gRPC 处理中的调用完成, 超时后强制关闭
+	AddHook("http-server", PriorityServer, 0, func(ctx context.Context) error {
+		Log.Info("shutting down http server")
 
-		grpcServer.Stop()
+		return srv.Shutdown(ctx)
+	})
 
-		if err := srv.Shutdown(context.Background()); err != nil {
-			Log.Info("shutting down server-err")
-		} else {
-			Log.Info("shutting down server-done")
+	AddHook("grpc-server", PriorityServer, 0, func(ctx context.Context) error {
+		Log.Info("shutting down grpc server")
+
+		stopped := make(chan struct{})
+
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			grpcServer.Stop()
+
+			return ctx.Err()
 		}
 	})
 
@@ -207,7 +204,7 @@ func (a *Application) Run(f func(*grpc.Server)) {
 		Log.Infof("%s: %s", fmt.Sprintf("%-20s", "Http Address"), config.AppSetting.AppPort)
 		Log.Infof("%s! %s", fmt.Sprintf("%-20s", "Congratulations"), "Your server startup and running ~")
 
-		if err := srv.Serve(l); err != nil {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			Log.Info(err.Error())
 		}
 	}()
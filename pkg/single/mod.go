@@ -1,72 +1,196 @@
 package single
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"os/signal"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
 )
 
-var shutdownSignals chan os.Signal
-var funcChannel chan func()
-var isStarted bool = false
-var funcArray []func()
-var done chan struct{}
+// 关闭顺序, 数值小的先执行, 相同优先级按注册顺序执行
+const (
+	PriorityServer   = 100 // 停止 HTTP / gRPC 监听, 等待处理中的请求完成
+	PriorityConsumer = 200 // 停止 Kafka 消费者、定时任务, 不再产生新的工作
+	PriorityProducer = 300 // flush 并关闭 Kafka 生产者
+	PriorityClient   = 400 // 关闭 MySQL / Redis / ZooKeeper 等客户端
+	PriorityLogger   = 500 // 最后 flush 日志
+)
+
+type ShutdownConf struct {
+	// DrainPeriod 收到退出信号后, 就绪探针先报告 not-ready, 等待该时长 (负载均衡摘除本节点) 再关闭监听
+	DrainPeriod time.Duration `mapstructure:"SHUTDOWN_DRAIN_PERIOD"`
+	// HookTimeout 未指定超时的关闭钩子的默认超时
+	HookTimeout time.Duration `mapstructure:"SHUTDOWN_HOOK_TIMEOUT"`
+}
+
+var Settings = &ShutdownConf{
+	DrainPeriod: time.Second * 5,
+	HookTimeout: time.Second * 10,
+}
+
+type hook struct {
+	name     string
+	priority int
+	timeout  time.Duration
+	seq      int
+	fn       func(ctx context.Context) error
+}
+
+var (
+	mu    sync.Mutex
+	hooks []*hook
+
+	draining atomic.Bool
+
+	startOnce    sync.Once
+	shutdownOnce sync.Once
+	done         = make(chan struct{})
+)
+
+// AddHook 注册关闭钩子: 按 priority 从小到大执行, fn 超过 timeout (<= 0 时使用 SHUTDOWN_HOOK_TIMEOUT) 未返回时不再等待, 继续执行下一个
+func AddHook(name string, priority int, timeout time.Duration, fn func(ctx context.Context) error) {
+	startOnce.Do(start)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	hooks = append(hooks, &hook{name: name, priority: priority, timeout: timeout, seq: len(hooks), fn: fn})
+}
 
+// AddShutDownHook 注册关闭钩子, 在 PriorityServer 阶段执行, 超时为 SHUTDOWN_HOOK_TIMEOUT
 func AddShutDownHook(f func()) {
-	if !isStarted {
-		shutdownSignals = make(chan os.Signal, 1)
-		funcChannel = make(chan func())
-		done = make(chan struct{})
-		signal.Notify(shutdownSignals, syscall.SIGINT, syscall.SIGTERM)
+	AddHook(funcName(f), PriorityServer, 0, func(context.Context) error {
+		f()
 
-		start()
+		return nil
+	})
+}
 
-		isStarted = true
+func funcName(f func()) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer()); fn != nil {
+		return fn.Name()
 	}
 
-	funcChannel <- f
+	return "unknown"
+}
+
+// Draining 已收到退出信号, 就绪探针应报告 not-ready
+func Draining() bool {
+	return draining.Load()
 }
 
+// WaitShutDown 阻塞直到所有关闭钩子执行完成
 func WaitShutDown() {
+	startOnce.Do(start)
+
 	<-done
 }
 
+// Shutdown 主动触发关闭流程, 多次调用只执行一次
+func Shutdown() {
+	shutdownOnce.Do(func() {
+		draining.Store(true)
+
+		if Settings.DrainPeriod > 0 {
+			Log.Infof("Shutdown-Draining: DrainPeriod=%s", Settings.DrainPeriod)
+
+			time.Sleep(Settings.DrainPeriod)
+		}
+
+		executeHooks()
+
+		close(done)
+	})
+}
+
+func start() {
+	signals := make(chan os.Signal, 2)
+
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+
+	go func() {
+		sig := <-signals
+
+		Log.Infof("收到退出信号, 开始关闭: signal=%v", sig)
+
+		// 关闭过程中再次收到信号时立即退出
+		go func() {
+			sig := <-signals
+
+			Log.Warnf("再次收到退出信号, 强制退出: signal=%v", sig)
+
+			os.Exit(1)
+		}()
+
+		Shutdown()
+	}()
+}
+
 func executeHooks() {
-	for _, f := range funcArray {
-		f()
+	mu.Lock()
+
+	ordered := make([]*hook, len(hooks))
+
+	copy(ordered, hooks)
+
+	mu.Unlock()
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].priority != ordered[j].priority {
+			return ordered[i].priority < ordered[j].priority
+		}
+
+		return ordered[i].seq < ordered[j].seq
+	})
+
+	for _, h := range ordered {
+		h.run()
 	}
 }
 
-func start() {
+func (h *hook) run() {
+	timeout := h.timeout
+
+	if timeout <= 0 {
+		timeout = Settings.HookTimeout
+	}
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), timeout)
+
+	defer cancelFunc()
+
+	start := time.Now()
+	result := make(chan error, 1)
+
 	go func() {
-		shutdown := false
-
-		for !shutdown {
-			select {
-			case <-shutdownSignals:
-				for signal := range shutdownSignals {
-					if signal == syscall.SIGTERM || signal == syscall.SIGQUIT {
-						Log.Infof("kill -15 退出进程: signal=%v", signal)
-						break
-					} else if signal == syscall.SIGILL {
-						Log.Infof("kill -4 退出进程: signal=%v", signal)
-						break
-					} else {
-						Log.Infof("kill -? 退出进程: signal=%v", signal)
-						break
-					}
-				}
-
-				executeHooks()
-
-				shutdown = true
-			case f := <-funcChannel:
-				funcArray = append(funcArray, f)
+		defer func() {
+			if p := recover(); p != nil {
+				result <- fmt.Errorf("panic: %v", p)
 			}
-		}
+		}()
 
-		done <- struct{}{}
+		result <- h.fn(ctx)
 	}()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			Log.Errorf("Shutdown-Hook-Failed: Name=%s, Priority=%d, Elapsed=%s, Error=%s", h.name, h.priority, time.Since(start), err.Error())
+
+			return
+		}
+
+		Log.Infof("Shutdown-Hook-Done: Name=%s, Priority=%d, Elapsed=%s", h.name, h.priority, time.Since(start))
+	case <-ctx.Done():
+		Log.Errorf("Shutdown-Hook-Timeout: Name=%s, Priority=%d, Timeout=%s", h.name, h.priority, timeout)
+	}
 }
@@ -0,0 +1,453 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/utils"
+)
+
+// Exporter 批量导出已结束的 Span
+type Exporter interface {
+	Export(ctx context.Context, spans []*Span) error
+	Shutdown(ctx context.Context) error
+}
+
+func newExporter() (Exporter, error) {
+	switch Settings.Exporter {
+	case ExporterOTLP:
+		return NewOTLPExporter(Settings.OTLPEndpoint, parseHeaders(Settings.OTLPHeaders), Settings.OTLPTimeout), nil
+	case ExporterFile:
+		return NewFileExporter(Settings.FilePath)
+	case ExporterNone, "":
+		return nopExporter{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown exporter: %s", Settings.Exporter)
+}
+
+func parseHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+
+	for _, item := range strings.Split(s, ",") {
+		if k, v, ok := strings.Cut(item, "="); ok && strings.TrimSpace(k) != "" {
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+
+	return headers
+}
+
+type nopExporter struct{}
+
+func (nopExporter) Export(context.Context, []*Span) error {
+	return nil
+}
+
+func (nopExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// OTLPExporter 以 OTLP/HTTP JSON 发送到 collector, 如 http://localhost:4318/v1/traces
+type OTLPExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+func NewOTLPExporter(endpoint string, headers map[string]string, timeout time.Duration) *OTLPExporter {
+	return &OTLPExporter{endpoint: endpoint, headers: headers, client: &http.Client{Timeout: timeout}}
+}
+
+func (e *OTLPExporter) Export(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(encode(spans))
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("otlp export: HTTP %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (e *OTLPExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+
+	return nil
+}
+
+// FileExporter 每批一行 OTLP/JSON (ExportTraceServiceRequest), 追加到文件
+type FileExporter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileExporter(path string) (*FileExporter, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(utils.RootDir(), path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileExporter{file: file}, nil
+}
+
+func (e *FileExporter) Export(_ context.Context, spans []*Span) error {
+	line, err := json.Marshal(encode(spans))
+
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, err = e.file.Write(append(line, '\n'))
+
+	return err
+}
+
+func (e *FileExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.file.Close()
+}
+
+// processor 已结束的 Span 的队列, 由一个 goroutine 批量导出
+type processor struct {
+	exporter Exporter
+	queue    chan *Span
+	flush    chan chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	dropped  atomic.Int64
+}
+
+var active atomic.Pointer[processor]
+
+func startProcessor(exporter Exporter) {
+	p := &processor{
+		exporter: exporter,
+		queue:    make(chan *Span, max(Settings.QueueSize, 1)),
+		flush:    make(chan chan struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	active.Store(p)
+
+	go p.run()
+}
+
+func enqueue(s *Span) {
+	p := active.Load()
+
+	if p == nil {
+		return
+	}
+
+	select {
+	case p.queue <- s:
+	default:
+		// 导出跟不上时丢弃, 不阻塞业务
+		if n := p.dropped.Add(1); n == 1 || n%1000 == 0 {
+			Log.Warnf("Tracing-Span-Dropped: Dropped=%d, QueueSize=%d", n, cap(p.queue))
+		}
+	}
+}
+
+// Flush 立即导出队列中的 Span, 用于测试或进程退出前
+func Flush(ctx context.Context) {
+	p := active.Load()
+
+	if p == nil {
+		return
+	}
+
+	flushed := make(chan struct{})
+
+	select {
+	case p.flush <- flushed:
+	case <-p.done:
+		return
+	case <-ctx.Done():
+		return
+	}
+
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+	}
+}
+
+func (p *processor) run() {
+	defer close(p.done)
+
+	batchSize := max(Settings.BatchSize, 1)
+	interval := Settings.FlushInterval
+
+	if interval <= 0 {
+		interval = time.Second * 5
+	}
+
+	ticker := time.NewTicker(interval)
+
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		ctx, cancelFunc := context.WithTimeout(context.Background(), Settings.OTLPTimeout+time.Second)
+
+		if err := p.exporter.Export(ctx, batch); err != nil {
+			Log.Errorf("Tracing-Export-Failed: Exporter=%s, Spans=%d, Error=%s", Settings.Exporter, len(batch), err.Error())
+		}
+
+		cancelFunc()
+
+		batch = make([]*Span, 0, batchSize)
+	}
+
+	drain := func() {
+		for {
+			select {
+			case s := <-p.queue:
+				batch = append(batch, s)
+
+				if len(batch) >= batchSize {
+					export()
+				}
+			default:
+				export()
+
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case s := <-p.queue:
+			batch = append(batch, s)
+
+			if len(batch) >= batchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case flushed := <-p.flush:
+			drain()
+
+			close(flushed)
+		case <-p.stop:
+			drain()
+
+			return
+		}
+	}
+}
+
+// shutdownProcessor 导出剩余的 Span 后关闭导出器, 已注册为关闭钩子
+func shutdownProcessor(ctx context.Context) error {
+	p := active.Swap(nil)
+
+	if p == nil {
+		return nil
+	}
+
+	close(p.stop)
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return p.exporter.Shutdown(ctx)
+}
+
+// OTLP/JSON 编码: trace id 与 span id 为十六进制字符串, 64 位整数为十进制字符串
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 1: OK, 2: ERROR
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func valueOf(v any) otlpValue {
+	switch v := v.(type) {
+	case string:
+		return otlpValue{StringValue: &v}
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case int:
+		s := strconv.Itoa(v)
+
+		return otlpValue{IntValue: &s}
+	case int32:
+		s := strconv.FormatInt(int64(v), 10)
+
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &v}
+	}
+
+	s := fmt.Sprintf("%v", v)
+
+	return otlpValue{StringValue: &s}
+}
+
+func keyValues(attrs map[string]any) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	out := make([]otlpKeyValue, 0, len(keys))
+
+	for _, k := range keys {
+		out = append(out, otlpKeyValue{Key: k, Value: valueOf(attrs[k])})
+	}
+
+	return out
+}
+
+func encode(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+
+	for _, s := range spans {
+		s.mu.Lock()
+
+		span := otlpSpan{
+			TraceID:           s.sc.TraceID.String(),
+			SpanID:            s.sc.SpanID.String(),
+			TraceState:        s.sc.TraceState,
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        keyValues(s.attrs),
+		}
+
+		if s.parent.IsValid() {
+			span.ParentSpanID = s.parent.String()
+		}
+
+		if s.failed {
+			span.Status = &otlpStatus{Code: 2, Message: s.errMsg}
+		}
+
+		s.mu.Unlock()
+
+		out = append(out, span)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: keyValues(map[string]any{"service.name": Settings.ServiceName})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/chunhui2001/zero4go"}, Spans: out}},
+	}}}
+}
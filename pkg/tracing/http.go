@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware 读取请求头中的 traceparent, 为每个请求创建 server Span; handler 中通过 c.Request.Context() 取得
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Settings.Enable {
+			c.Next()
+
+			return
+		}
+
+		ctx := ExtractHTTP(c.Request.Context(), c.Request.Header)
+		ctx, span := Start(ctx, c.Request.Method, KindServer)
+
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		route := c.FullPath()
+
+		if route != "" {
+			span.SetName(c.Request.Method + " " + route)
+			span.SetAttr("http.route", route)
+		}
+
+		status := c.Writer.Status()
+
+		span.SetAttr("http.request.method", c.Request.Method)
+		span.SetAttr("url.path", c.Request.URL.Path)
+		span.SetAttr("client.address", c.ClientIP())
+		span.SetAttr("http.response.status_code", status)
+
+		if status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("HTTP %d", status))
+		}
+
+		if len(c.Errors) > 0 {
+			span.SetError(c.Errors.Last())
+		}
+	}
+}
+
+// StartHTTPClient 为发出的 HTTP 请求创建 client Span 并写入 traceparent; 调用方在收到响应后调用 EndHTTPClient
+func StartHTTPClient(req *http.Request) (*http.Request, *Span) {
+	ctx, span := Start(req.Context(), req.Method, KindClient)
+
+	if span == nil {
+		return req, nil
+	}
+
+	req = req.WithContext(ctx)
+
+	InjectHTTP(ctx, req.Header)
+
+	span.SetAttr("http.request.method", req.Method)
+	span.SetAttr("url.full", req.URL.Redacted())
+	span.SetAttr("server.address", req.URL.Host)
+
+	return req, span
+}
+
+func EndHTTPClient(span *Span, resp *http.Response, err error) {
+	if resp != nil {
+		span.SetAttr("http.response.status_code", resp.StatusCode)
+
+		if resp.StatusCode >= http.StatusBadRequest {
+			span.SetError(fmt.Errorf("HTTP %d", resp.StatusCode))
+		}
+	}
+
+	span.SetError(err)
+	span.End()
+}
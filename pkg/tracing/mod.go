@@ -0,0 +1,179 @@
+// Package tracing 分布式追踪: 在 HTTP / gRPC / Kafka 之间按 W3C traceparent 传递上下文,
+// 为请求、SQL、Redis 等调用创建 Span, 以 OTLP/JSON 导出到 collector 或本地文件
+//
+//	ctx, span := tracing.Start(ctx, "order.create", tracing.KindInternal)
+//	defer span.End()
+//
+//	span.SetAttr("order.id", id)
+package tracing
+
+import (
+	"context"
+	"time"
+
+	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/single"
+)
+
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+// 导出方式
+const (
+	ExporterOTLP = "otlp" // POST OTLP/JSON 到 TRACING_OTLP_ENDPOINT
+	ExporterFile = "file" // 每批一行 OTLP/JSON 追加到 TRACING_FILE_PATH, 用于本地调试和测试
+	ExporterNone = "none" // 只传递上下文, 不导出
+)
+
+type TracingConf struct {
+	Enable      bool   `mapstructure:"TRACING_ENABLE"`
+	ServiceName string `mapstructure:"TRACING_SERVICE_NAME"` // 为空时使用 APP_NAME
+	// SampleRatio 根 Span 的采样比例 0~1; 上游传入 traceparent 时沿用上游的采样结果
+	SampleRatio float64 `mapstructure:"TRACING_SAMPLE_RATIO"`
+	Exporter    string  `mapstructure:"TRACING_EXPORTER"` // otlp / file / none
+
+	OTLPEndpoint string        `mapstructure:"TRACING_OTLP_ENDPOINT"`
+	OTLPHeaders  string        `mapstructure:"TRACING_OTLP_HEADERS"` // 逗号分隔的 key=value, 如鉴权头
+	OTLPTimeout  time.Duration `mapstructure:"TRACING_OTLP_TIMEOUT"`
+
+	FilePath string `mapstructure:"TRACING_FILE_PATH"` // 相对路径基于 utils.RootDir()
+
+	// 已结束的 Span 先放入队列, 攒够 BatchSize 或每隔 FlushInterval 导出一次; 队列满时丢弃
+	QueueSize     int           `mapstructure:"TRACING_QUEUE_SIZE"`
+	BatchSize     int           `mapstructure:"TRACING_BATCH_SIZE"`
+	FlushInterval time.Duration `mapstructure:"TRACING_FLUSH_INTERVAL"`
+}
+
+var Settings = &TracingConf{
+	Enable:        false,
+	SampleRatio:   1,
+	Exporter:      ExporterOTLP,
+	OTLPEndpoint:  "http://localhost:4318/v1/traces",
+	OTLPTimeout:   time.Second * 5,
+	FilePath:      "./logs/traces.json",
+	QueueSize:     2048,
+	BatchSize:     512,
+	FlushInterval: time.Second * 5,
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+func init() {
+	// Log.Ctx(ctx) 输出 trace_id / span_id, 与导出的 Span 关联
+	AddContextField("trace_id", TraceIDFromContext)
+	AddContextField("span_id", func(ctx context.Context) string {
+		if sc := SpanContextFromContext(ctx); sc.IsValid() && !sc.Remote {
+			return sc.SpanID.String()
+		}
+
+		return ""
+	})
+}
+
+func Init() {
+	if !Settings.Enable {
+		Log.Infof("Tracing-Disabled: val=%t", Settings.Enable)
+
+		return
+	}
+
+	exporter, err := newExporter()
+
+	if err != nil {
+		Log.Errorf("Tracing-Init-Failed: Exporter=%s, Error=%s", Settings.Exporter, err.Error())
+
+		Settings.Enable = false
+
+		return
+	}
+
+	startProcessor(exporter)
+
+	// 排在 HTTP / gRPC / 消费者之后, 导出关闭过程中结束的 Span
+	single.AddHook("tracing", single.PriorityProducer, 0, shutdownProcessor)
+
+	Log.Infof("Tracing-Initialized: ServiceName=%s, Exporter=%s, SampleRatio=%g", Settings.ServiceName, Settings.Exporter, Settings.SampleRatio)
+}
+
+// Start 创建 ctx 中 Span (或上游传入的 SpanContext) 的子 Span, 没有时创建新的 trace; 未启用时返回 (ctx, nil)
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if !Settings.Enable {
+		return ctx, nil
+	}
+
+	parent := SpanContextFromContext(ctx)
+
+	sc := SpanContext{SpanID: newSpanID()}
+
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Flags = parent.Flags
+		sc.TraceState = parent.TraceState
+	} else {
+		sc.TraceID = newTraceID()
+
+		if sampled(sc.TraceID) {
+			sc.Flags = flagSampled
+		}
+	}
+
+	span := &Span{
+		name:      name,
+		kind:      kind,
+		sc:        sc,
+		parent:    parent.SpanID,
+		start:     time.Now(),
+		recording: sc.IsSampled(),
+	}
+
+	if span.recording {
+		span.attrs = make(map[string]any)
+	}
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext 当前的 Span, 没有时返回 nil
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+
+	return span
+}
+
+// SpanContextFromContext 当前 Span 的 SpanContext, 没有时为上游传入的 SpanContext
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := FromContext(ctx); span != nil {
+		return span.sc
+	}
+
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+
+	return sc
+}
+
+// TraceIDFromContext 当前 trace id, 没有时返回空字符串, 用于日志关联
+func TraceIDFromContext(ctx context.Context) string {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		return sc.TraceID.String()
+	}
+
+	return ""
+}
+
+// ContextWithRemote 保存上游传入的 SpanContext, 之后 Start 的 Span 以其为父
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+
+	sc.Remote = true
+
+	// 覆盖 ctx 中已有的 Span, 如消费消息时以消息头中的 trace 为父
+	ctx = context.WithValue(ctx, spanKey{}, (*Span)(nil))
+
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
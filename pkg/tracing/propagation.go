@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+)
+
+// Inject 把当前的 traceparent / tracestate 写入 set, 用于请求头、消息头等
+func Inject(ctx context.Context, set func(key string, value string)) {
+	if !Settings.Enable {
+		return
+	}
+
+	sc := SpanContextFromContext(ctx)
+
+	if !sc.IsValid() {
+		return
+	}
+
+	set(HeaderTraceparent, sc.Traceparent())
+
+	if sc.TraceState != "" {
+		set(HeaderTracestate, sc.TraceState)
+	}
+}
+
+// Extract 从 get 读取 traceparent / tracestate, 格式错误时忽略 (开始新的 trace)
+func Extract(ctx context.Context, get func(key string) string) context.Context {
+	if !Settings.Enable {
+		return ctx
+	}
+
+	value := get(HeaderTraceparent)
+
+	if value == "" {
+		return ctx
+	}
+
+	sc, err := ParseTraceparent(value)
+
+	if err != nil {
+		return ctx
+	}
+
+	sc.TraceState = get(HeaderTracestate)
+
+	return ContextWithRemote(ctx, sc)
+}
+
+func InjectHTTP(ctx context.Context, header http.Header) {
+	Inject(ctx, header.Set)
+}
+
+func ExtractHTTP(ctx context.Context, header http.Header) context.Context {
+	return Extract(ctx, header.Get)
+}
+
+// InjectMap 用于 gkafkav2.Msg.Headers 这类 map 形式的消息头
+func InjectMap(ctx context.Context, headers map[string][]byte) {
+	Inject(ctx, func(key string, value string) {
+		headers[key] = []byte(value)
+	})
+}
+
+func ExtractMap(ctx context.Context, headers map[string][]byte) context.Context {
+	return Extract(ctx, func(key string) string {
+		return string(headers[key])
+	})
+}
@@ -0,0 +1,222 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpanKind 与 OTLP 的取值相同
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+	KindProducer SpanKind = 4
+	KindConsumer SpanKind = 5
+)
+
+const flagSampled byte = 0x01
+
+type TraceID [16]byte
+
+type SpanID [8]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext 跨进程传递的部分, 即 W3C traceparent / tracestate
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+	Remote     bool // 从请求头、消息头中解析得到
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&flagSampled != 0
+}
+
+// Traceparent 格式: 00-{trace-id}-{parent-id}-{flags}
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+var errInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent 解析 W3C traceparent, 未知版本按 00 的格式读取前四段
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(s), "-")
+
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, errInvalidTraceparent
+	}
+
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, errInvalidTraceparent
+	}
+
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, errInvalidTraceparent
+	}
+
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, errInvalidTraceparent
+	}
+
+	flags, err := hex.DecodeString(parts[3])
+
+	if err != nil || !sc.IsValid() {
+		return sc, errInvalidTraceparent
+	}
+
+	sc.Flags = flags[0]
+
+	return sc, nil
+}
+
+func newTraceID() TraceID {
+	var t TraceID
+
+	_, _ = rand.Read(t[:])
+
+	return t
+}
+
+func newSpanID() SpanID {
+	var s SpanID
+
+	_, _ = rand.Read(s[:])
+
+	return s
+}
+
+// Span 一次操作; 未采样的 Span 只用于向下游传递 traceparent, 不记录也不导出.
+// 所有方法对 nil 安全, 未启用追踪时 Start 返回 nil
+type Span struct {
+	mu sync.Mutex
+
+	name      string
+	kind      SpanKind
+	sc        SpanContext
+	parent    SpanID
+	start     time.Time
+	end       time.Time
+	attrs     map[string]any
+	errMsg    string
+	failed    bool
+	recording bool
+	ended     bool
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+
+	return s.sc
+}
+
+func (s *Span) IsRecording() bool {
+	return s != nil && s.recording
+}
+
+// SetName 修改名称, 如 HTTP 请求在路由匹配之后才知道路由模板
+func (s *Span) SetName(name string) {
+	if !s.IsRecording() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.name = name
+}
+
+// SetAttr 值为 string, bool, int, int64, float64, 其他类型按 %v 转为字符串
+func (s *Span) SetAttr(key string, value any) {
+	if !s.IsRecording() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attrs[key] = value
+}
+
+// SetError 标记失败, err 为 nil 时不做处理
+func (s *Span) SetError(err error) {
+	if err == nil || !s.IsRecording() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failed = true
+	s.errMsg = err.Error()
+}
+
+// End 结束并交给导出器, 多次调用只有第一次生效
+func (s *Span) End() {
+	if !s.IsRecording() {
+		return
+	}
+
+	s.mu.Lock()
+
+	if s.ended {
+		s.mu.Unlock()
+
+		return
+	}
+
+	s.ended = true
+	s.end = time.Now()
+
+	s.mu.Unlock()
+
+	enqueue(s)
+}
+
+// sampled 根 Span 按 trace id 的低 8 字节与 TRACING_SAMPLE_RATIO 比较, 同一 trace 在各服务的结果一致
+func sampled(traceID TraceID) bool {
+	ratio := Settings.SampleRatio
+
+	if ratio >= 1 {
+		return true
+	}
+
+	if ratio <= 0 {
+		return false
+	}
+
+	return binary.BigEndian.Uint64(traceID[8:])>>1 < uint64(ratio*(1<<63))
+}
@@ -1,6 +1,7 @@
 package upstream
 
 import (
+	"fmt"
 	"math/rand"
 	"net"
 	"net/http"
@@ -10,9 +11,13 @@ import (
 	"time"
 
 	. "github.com/chunhui2001/zero4go/pkg/logs" //nolint:staticcheck
+	"github.com/chunhui2001/zero4go/pkg/tracing"
 	"github.com/gin-gonic/gin"
 )
 
+// TargetKey gin.Context 中本次请求转发到的上游地址, 由访问日志输出
+const TargetKey = "upstream"
+
 var (
 	defaultTimeOut      int = 150 // * time.Second
 	maxIdleConns        int = 100
@@ -120,8 +125,15 @@ func Proxy(c *gin.Context, prefix string, remotes ...string) {
 
 	// httputil.ReverseProxy{}
 
+	c.Set(TargetKey, currentRemote)
+
 	proxy := CustomerSingleHostReverseProxy(upstream)
 
+	// client Span 的父为 tracing 中间件创建的 server Span, 下游服务收到的 traceparent 指向该 client Span
+	ctx, span := tracing.Start(c.Request.Context(), c.Request.Method+" "+upstream.Host, tracing.KindClient)
+
+	defer span.End()
+
 	proxy.Director = func(req *http.Request) {
 
 		RequestURI := req.URL.Path
@@ -133,6 +145,8 @@ func Proxy(c *gin.Context, prefix string, remotes ...string) {
 		req.URL.Host = upstream.Host
 		req.URL.Path = requestPath
 
+		tracing.InjectHTTP(ctx, req.Header)
+
 		// c.Request.WithContext(context.WithValue(c.Request.Context(), "ProxyReverse", utils.MapOf("Upstream", currentRemote, "RequestPath", requestPath)))
 
 		Log.Infof(`Upstream: URI=%s, Upstream=%s, ProxyPath=%s`, RequestURI, currentRemote, requestPath)
@@ -140,6 +154,14 @@ func Proxy(c *gin.Context, prefix string, remotes ...string) {
 	}
 
 	proxy.ServeHTTP(c.Writer, c.Request)
+
+	span.SetAttr("http.request.method", c.Request.Method)
+	span.SetAttr("server.address", upstream.Host)
+	span.SetAttr("http.response.status_code", c.Writer.Status())
+
+	if c.Writer.Status() >= http.StatusInternalServerError {
+		span.SetError(fmt.Errorf("HTTP %d", c.Writer.Status()))
+	}
 }
 
 // Any